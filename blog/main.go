@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/H-kang-better/msgo"
@@ -75,7 +76,7 @@ func main() {
 	g.Get("/HTML", func(ctx *msgo.Context) {
 		fmt.Println("HTML")
 		ctx.HTML(http.StatusOK, "<h1>你好 码神之路</h1>")
-	})
+	}).Name("user.html")
 	g.Get("/HTMLTemplate", func(ctx *msgo.Context) {
 		fmt.Println("HTMLTemplate")
 		ctx.HTMLTemplate("login.html", template.FuncMap{}, "", "tpl/index.html", "tpl/login.html", "tpl/header.html")
@@ -85,6 +86,8 @@ func main() {
 		fmt.Println("HTMLTemplateGlob")
 		ctx.HTMLTemplateGlob("login.html", template.FuncMap{}, "", "tpl/*.html")
 	})
+	// 静态文件，css 和图片
+	engine.Static("/static", "static", msgo.StaticConfig{CacheControl: "public, max-age=3600"})
 	// test func template 提前加载模板，比上面的加载方式简单
 	engine.LoadTemplate("tpl/*.html") // 提前将模板加载到内存中
 	g.Get("/template", func(ctx *msgo.Context) {
@@ -230,6 +233,10 @@ func main() {
 	})
 	// test 线程池
 	p, _ := mspool.NewPool(1)
+	engine.OnShutdown(func(ctx context.Context) error {
+		p.Release()
+		return nil
+	})
 	g.Post("/pool", func(ctx *msgo.Context) {
 		currentTime := time.Now().UnixMilli()
 		var wg sync.WaitGroup
@@ -265,7 +272,9 @@ func main() {
 		ctx.JSON(http.StatusOK, "success")
 	})
 
-	engine.Run()
+	if err := engine.RunWithGracefulShutdown(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func login() *BlogError {
@@ -0,0 +1,73 @@
+package msgo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// paramConstraints 内置的参数类型约束，/article/:id<int>
+var paramConstraints = map[string]func(value string) bool{
+	"int": func(value string) bool {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	},
+	"uint": func(value string) bool {
+		_, err := strconv.ParseUint(value, 10, 64)
+		return err == nil
+	},
+	"float": func(value string) bool {
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	},
+	"bool": func(value string) bool {
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	},
+	"alpha": regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+	"alnum": regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString,
+	"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+}
+
+// parseParam 解析参数段，返回参数名和约束
+// :id -> id, "";  :id<int> -> id, int;  {id:[0-9]+} -> id, [0-9]+;  * -> *, ""
+func parseParam(segment string, path string) (key string, constraint string) {
+	switch {
+	case segment == "*":
+		return segment, ""
+	case segment[0] == ':':
+		key = segment[1:]
+		if i := strings.IndexByte(key, '<'); i >= 0 {
+			if key[len(key)-1] != '>' {
+				panic(fmt.Sprintf("msgo: invalid param '%s' in route '%s', missing '>'", segment, path))
+			}
+			key, constraint = key[:i], key[i+1:len(key)-1]
+		}
+	default:
+		// {id} {id:[0-9]+}
+		key = segment[1 : len(segment)-1]
+		if i := strings.IndexByte(key, ':'); i >= 0 {
+			key, constraint = key[:i], key[i+1:]
+		}
+	}
+	if key == "" {
+		panic(fmt.Sprintf("msgo: param '%s' in route '%s' must have a name", segment, path))
+	}
+	return key, constraint
+}
+
+// compileConstraint 将约束转换成校验函数，不是内置类型的约束按正则表达式处理
+func compileConstraint(constraint string, path string) func(value string) bool {
+	if constraint == "" {
+		return nil
+	}
+	if check, ok := paramConstraints[constraint]; ok {
+		return check
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		panic(fmt.Sprintf("msgo: invalid param constraint '%s' in route '%s': %v", constraint, path, err))
+	}
+	return re.MatchString
+}
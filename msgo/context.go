@@ -1,6 +1,8 @@
 package msgo
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,25 +13,372 @@ import (
 	"io"
 	"log"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const defaultMultipartMemory = 32 << 20 // 32M
 
 type Context struct {
-	W                     http.ResponseWriter
+	W                     ResponseWriter
 	R                     *http.Request
 	engine                *Engine
+	params                Params
 	queryCache            url.Values
 	formCache             url.Values
 	DisallowUnknownFields bool
 	IsValidate            bool
 	StatusCode            int
 	Logger                *msLog.Logger
+	handlers              HandlersChain
+	index                 int // 正在执行的处理函数在 handlers 中的下标
+	mu                    sync.RWMutex
+	keys                  map[string]any // 请求内中间件和处理函数之间传递数据，第一次 Set 时分配
+	writer                responseWriter // W 默认指向 writer，随 Context 复用
+}
+
+// handle 从头开始执行处理函数链
+func (c *Context) handle(handlers HandlersChain) {
+	c.handlers = handlers
+	c.index = -1
+	c.Next()
+}
+
+// Next 执行处理函数链中剩下的处理函数，只在中间件中调用
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort 中止处理函数链，剩下的处理函数不再执行，不影响当前函数的执行
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted 处理函数链是否已经中止
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus 写入状态码并中止处理函数链，ctx.AbortWithStatus(http.StatusUnauthorized)
+func (c *Context) AbortWithStatus(code int) {
+	c.StatusCode = code
+	c.W.WriteHeader(code)
+	c.W.WriteHeaderNow()
+	c.Abort()
+}
+
+// AbortWithStatusJSON 返回 JSON 并中止处理函数链
+func (c *Context) AbortWithStatusJSON(code int, data any) error {
+	c.Abort()
+	return c.JSON(code, data)
+}
+
+// reset 从 pool 中取出时重置上一个请求留下的状态
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.writer.reset(w, c.engine.Logger)
+	c.W = &c.writer
+	c.R = r
+	c.params = c.params[:0]
+	c.queryCache = nil
+	c.formCache = nil
+	c.DisallowUnknownFields = false
+	c.IsValidate = false
+	c.StatusCode = 0
+	c.Logger = c.engine.Logger
+	c.handlers = nil
+	c.index = -1
+	c.keys = nil
+}
+
+// Copy 返回当前 Context 的只读副本，处理函数返回后 Context 会被复用，
+// 在 goroutine 中（如 mspool 的任务）需要使用 Context 时先 Copy
+// 副本不能写响应，它的 context 不会随请求结束而取消
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		W:                     &copiedResponseWriter{header: c.W.Header().Clone(), status: c.W.Status(), size: c.W.Size()},
+		R:                     c.R.Clone(detachedContext{parent: c.R.Context()}),
+		engine:                c.engine,
+		params:                append(Params(nil), c.params...),
+		queryCache:            c.queryCache,
+		formCache:             c.formCache,
+		DisallowUnknownFields: c.DisallowUnknownFields,
+		IsValidate:            c.IsValidate,
+		StatusCode:            c.StatusCode,
+		Logger:                c.Logger,
+		index:                 abortIndex,
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.keys != nil {
+		cp.keys = make(map[string]any, len(c.keys))
+		for k, v := range c.keys {
+			cp.keys[k] = v
+		}
+	}
+	return cp
+}
+
+// ErrCopiedContext 通过 Copy 得到的 Context 写响应时返回的错误
+var ErrCopiedContext = errors.New("msgo: cannot write response with a copied Context")
+
+// copiedResponseWriter Copy 得到的 Context 使用的 ResponseWriter，响应已经交给原来的 Context 处理
+type copiedResponseWriter struct {
+	header http.Header
+	status int
+	size   int
+}
+
+func (w *copiedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *copiedResponseWriter) Write([]byte) (int, error) {
+	return 0, ErrCopiedContext
+}
+
+func (w *copiedResponseWriter) WriteHeader(int) {}
+
+func (w *copiedResponseWriter) WriteHeaderNow() {}
+
+func (w *copiedResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *copiedResponseWriter) Size() int {
+	return w.size
+}
+
+func (w *copiedResponseWriter) Written() bool {
+	return true
+}
+
+func (w *copiedResponseWriter) Flush() {}
+
+func (w *copiedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, ErrCopiedContext
+}
+
+func (w *copiedResponseWriter) CloseNotify() <-chan bool {
+	return nil
+}
+
+func (w *copiedResponseWriter) Push(string, *http.PushOptions) error {
+	return ErrCopiedContext
+}
+
+// detachedContext 保留请求 context 中的值，但不会随请求结束而取消
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
+
+// Param 获取路由中的路径参数，/order/get/:id 中的 id
+func (c *Context) Param(key string) string {
+	return c.params.ByName(key)
+}
+
+// GetParam 获取路由中的路径参数，ok 表示该参数是否存在
+func (c *Context) GetParam(key string) (string, bool) {
+	return c.params.Get(key)
+}
+
+// Params 获取路由中匹配到的所有路径参数
+func (c *Context) Params() Params {
+	return c.params
+}
+
+// ParamInt 获取 int 类型的路径参数，配合 /article/:id<int> 使用
+func (c *Context) ParamInt(key string) (int, error) {
+	return strconv.Atoi(c.Param(key))
+}
+
+// ParamInt64 获取 int64 类型的路径参数
+func (c *Context) ParamInt64(key string) (int64, error) {
+	return strconv.ParseInt(c.Param(key), 10, 64)
+}
+
+// ParamUint64 获取 uint64 类型的路径参数，配合 :id<uint> 使用
+func (c *Context) ParamUint64(key string) (uint64, error) {
+	return strconv.ParseUint(c.Param(key), 10, 64)
+}
+
+// ParamFloat64 获取 float64 类型的路径参数，配合 :price<float> 使用
+func (c *Context) ParamFloat64(key string) (float64, error) {
+	return strconv.ParseFloat(c.Param(key), 64)
+}
+
+// ParamBool 获取 bool 类型的路径参数，配合 :flag<bool> 使用
+func (c *Context) ParamBool(key string) (bool, error) {
+	return strconv.ParseBool(c.Param(key))
+}
+
+// CatchAll 获取 ** 匹配到的剩余路径，/user/** 匹配 /user/aa/bb 时返回 aa/bb
+func (c *Context) CatchAll() string {
+	return c.params.ByName("**")
+}
+
+// Set 保存请求内的数据，中间件中 ctx.Set("user", user)，处理函数中通过 Get 获取
+func (c *Context) Set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[string]any)
+	}
+	c.keys[key] = value
+}
+
+// Get 获取 Set 保存的数据，exists 表示 key 是否存在
+func (c *Context) Get(key string) (value any, exists bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, exists = c.keys[key]
+	return
+}
+
+// MustGet 获取 Set 保存的数据，key 不存在时 panic
+func (c *Context) MustGet(key string) any {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic(fmt.Sprintf("msgo: key '%s' does not exist", key))
+}
+
+// GetAs 获取 Set 保存的数据并转换成 T 类型，key 不存在或者类型不匹配时 ok 为 false
+// user, ok := msgo.GetAs[*User](ctx, "user")
+func GetAs[T any](c *Context, key string) (value T, ok bool) {
+	if v, exists := c.Get(key); exists {
+		value, ok = v.(T)
+	}
+	return
+}
+
+// GetString 获取 string 类型的数据，不存在或者类型不匹配时返回零值，下同
+func (c *Context) GetString(key string) string {
+	value, _ := GetAs[string](c, key)
+	return value
+}
+
+func (c *Context) GetInt(key string) int {
+	value, _ := GetAs[int](c, key)
+	return value
+}
+
+func (c *Context) GetInt64(key string) int64 {
+	value, _ := GetAs[int64](c, key)
+	return value
+}
+
+func (c *Context) GetUint(key string) uint {
+	value, _ := GetAs[uint](c, key)
+	return value
+}
+
+func (c *Context) GetUint64(key string) uint64 {
+	value, _ := GetAs[uint64](c, key)
+	return value
+}
+
+func (c *Context) GetFloat64(key string) float64 {
+	value, _ := GetAs[float64](c, key)
+	return value
+}
+
+func (c *Context) GetBool(key string) bool {
+	value, _ := GetAs[bool](c, key)
+	return value
+}
+
+func (c *Context) GetTime(key string) time.Time {
+	value, _ := GetAs[time.Time](c, key)
+	return value
+}
+
+func (c *Context) GetDuration(key string) time.Duration {
+	value, _ := GetAs[time.Duration](c, key)
+	return value
+}
+
+func (c *Context) GetStringSlice(key string) []string {
+	value, _ := GetAs[[]string](c, key)
+	return value
+}
+
+func (c *Context) GetStringMap(key string) map[string]any {
+	value, _ := GetAs[map[string]any](c, key)
+	return value
+}
+
+func (c *Context) GetStringMapString(key string) map[string]string {
+	value, _ := GetAs[map[string]string](c, key)
+	return value
+}
+
+// Context 实现了 context.Context，可以直接传给数据库、http 客户端等，
+// 客户端断开连接或者服务关闭时，下游的调用会跟着取消
+var _ context.Context = (*Context)(nil)
+
+// Deadline 返回请求 context 的截止时间
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.R == nil {
+		return
+	}
+	return c.R.Context().Deadline()
+}
+
+// Done 返回请求 context 的 Done，请求取消时关闭
+func (c *Context) Done() <-chan struct{} {
+	if c.R == nil {
+		return nil
+	}
+	return c.R.Context().Done()
+}
+
+// Err 返回请求 context 取消的原因
+func (c *Context) Err() error {
+	if c.R == nil {
+		return nil
+	}
+	return c.R.Context().Err()
+}
+
+// Value 先从请求 context 中查找，找不到时 key 为 string 的从 Set 保存的数据中查找
+func (c *Context) Value(key any) any {
+	if c.R != nil {
+		if value := c.R.Context().Value(key); value != nil {
+			return value
+		}
+	}
+	if k, ok := key.(string); ok {
+		value, _ := c.Get(k)
+		return value
+	}
+	return nil
 }
 
 // initQueryCache 初始化缓存
@@ -322,6 +671,7 @@ func (c *Context) HTMLTemplate(name string, funcMap template.FuncMap, data any,
 func (c *Context) HTMLTemplateGlob(name string, funcMap template.FuncMap, data any, pattern string) {
 	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
 	t := template.New(name)
+	t.Funcs(c.engine.FuncMap())
 	t.Funcs(funcMap)
 	t, err := t.ParseGlob(pattern)
 	if err != nil {
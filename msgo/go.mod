@@ -2,12 +2,16 @@ module github.com/H-kang-better/msgo
 
 go 1.19
 
+require (
+	github.com/go-playground/validator/v10 v10.13.0
+	golang.org/x/net v0.23.0
+)
+
 require (
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
-	github.com/go-playground/validator/v10 v10.13.0 // indirect
 	github.com/leodido/go-urn v1.2.3 // indirect
-	golang.org/x/crypto v0.7.0 // indirect
-	golang.org/x/sys v0.6.0 // indirect
-	golang.org/x/text v0.8.0 // indirect
+	golang.org/x/crypto v0.21.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,116 @@
+package msgo
+
+import "strings"
+
+// hostRouter 一个域名对应的路由树
+type hostRouter struct {
+	pattern    string
+	labels     []string // 按 . 拆分后的域名，:tenant 表示参数
+	paramCount int      // 域名参数的个数
+	group      *routerGroup
+}
+
+// Host 创建域名分组，分组下的路由只匹配 Host 请求头为该域名的请求
+// 支持域名参数，如 :tenant.example.com，参数值通过 ctx.Param("tenant") 获取
+// 精确的域名优先于带参数的域名，都没有匹配上，或者域名分组中没有请求的路径时，使用 Engine 上注册的路由
+func (r *router) Host(pattern string) *routerGroup {
+	pattern = strings.ToLower(pattern)
+	for _, h := range r.hosts {
+		if h.pattern == pattern {
+			return h.group
+		}
+	}
+	labels := strings.Split(pattern, ".")
+	paramCount := 0
+	for _, label := range labels {
+		if isHostParam(label) {
+			paramCount++
+		}
+	}
+	g := &routerGroup{
+		engine: r.engine,
+		host:   pattern,
+		tree:   &treeNode{},
+	}
+	h := &hostRouter{pattern: pattern, labels: labels, paramCount: paramCount, group: g}
+	// 精确的域名排在带参数的域名前面
+	index := len(r.hosts)
+	if paramCount == 0 {
+		for i, old := range r.hosts {
+			if old.paramCount > 0 {
+				index = i
+				break
+			}
+		}
+	}
+	r.hosts = append(r.hosts, nil)
+	copy(r.hosts[index+1:], r.hosts[index:])
+	r.hosts[index] = h
+	return g
+}
+
+func isHostParam(label string) bool {
+	return len(label) > 1 && label[0] == ':'
+}
+
+// hostTree 根据 Host 请求头选择路由树，域名参数追加到 params 中
+func (r *router) hostTree(host string, params Params) (*treeNode, Params) {
+	if len(r.hosts) == 0 {
+		return r.tree, params
+	}
+	// 去掉端口，[::1]:8111 -> [::1]
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	for _, h := range r.hosts {
+		if ps, ok := h.match(host, params); ok {
+			return h.group.tree, ps
+		}
+	}
+	return r.tree, params
+}
+
+// match 逐段比较域名，不分配内存
+func (h *hostRouter) match(host string, params Params) (Params, bool) {
+	ps := params
+	for i, label := range h.labels {
+		end := strings.IndexByte(host, '.')
+		if i == len(h.labels)-1 {
+			if end >= 0 {
+				return params, false
+			}
+			end = len(host)
+		} else if end < 0 {
+			return params, false
+		}
+		value := host[:end]
+		if isHostParam(label) {
+			if value == "" {
+				return params, false
+			}
+			ps = append(ps, Param{Key: label[1:], Value: value})
+		} else if !strings.EqualFold(label, value) {
+			return params, false
+		}
+		if end < len(host) {
+			host = host[end+1:]
+		}
+	}
+	return ps, true
+}
+
+// maxParams 所有路由树中参数最多的个数，包括域名参数
+func (r *router) maxParams() int {
+	maxParams := r.tree.maxParams
+	for _, h := range r.hosts {
+		// 域名分组中没有的路径会继续匹配 Engine 上的路由
+		n := h.group.tree.maxParams
+		if r.tree.maxParams > n {
+			n = r.tree.maxParams
+		}
+		if n += h.paramCount; n > maxParams {
+			maxParams = n
+		}
+	}
+	return maxParams
+}
@@ -64,7 +64,6 @@ func (p *LogFormatterParams) StatusCodeColor() string {
 }
 
 func LoggerWithConfig(conf LoggerConfig, next HandlerFunc) HandlerFunc {
-	fmt.Sprintf("%#v", red)
 	formatter := conf.Formatter
 	if formatter == nil {
 		formatter = defaultLogFormatter
@@ -92,7 +91,7 @@ func LoggerWithConfig(conf LoggerConfig, next HandlerFunc) HandlerFunc {
 		ip, _, _ := net.SplitHostPort(strings.TrimSpace(ctx.R.RemoteAddr))
 		clientIP := net.ParseIP(ip)
 		method := ctx.R.Method
-		statusCode := ctx.StatusCode
+		statusCode := ctx.W.Status()
 
 		if raw != "" {
 			path = path + "?" + raw
@@ -110,9 +110,11 @@ func (l *Logger) Print(level LoggerLevel, msg any) {
 	formatter := l.Formatter.Formatter(param)
 	for _, out := range l.Outs {
 		if out.Out == os.Stdout {
+			// 控制台输出所有级别带颜色的日志，只输出一次，文件中的日志不带颜色
 			param.IsColor = true
-			formatter = l.Formatter.Formatter(param)
-			fmt.Fprintln(out.Out, formatter)
+			fmt.Fprintln(out.Out, l.Formatter.Formatter(param))
+			param.IsColor = false
+			continue
 		}
 		if out.Level == -1 || out.Level == level {
 			fmt.Fprintln(out.Out, formatter)
@@ -151,8 +153,9 @@ func (l *Logger) SetLogPath(logPath string) {
 }
 
 func (l *Logger) CheckFileSize(out *LoggerWriter) {
-	osFile := out.Out.(*os.File)
-	if osFile != nil {
+	osFile, ok := out.Out.(*os.File)
+	// 只有日志文件需要按大小切分，标准输出重定向到文件时也不切分
+	if ok && osFile != os.Stdout && osFile != os.Stderr {
 		stat, err := osFile.Stat()
 		if err != nil {
 			log.Println("logger checkFileSize error info :", err)
@@ -166,7 +169,7 @@ func (l *Logger) CheckFileSize(out *LoggerWriter) {
 		}
 		if size >= l.LogFileSize {
 			_, fileName := path.Split(osFile.Name())
-			name := fileName[0:strings.Index(fileName, ".")]
+			name := strings.TrimSuffix(fileName, path.Ext(fileName))
 			w := FileWriter(path.Join(l.logPath, msstrings.JoinStrings(name, ".", time.Now().UnixMilli(), ".log")))
 			if err != nil {
 				log.Println("logger checkFileSize error info :", err)
@@ -5,30 +5,76 @@ import (
 	msLog "github.com/H-kang-better/msgo/log"
 	"github.com/H-kang-better/msgo/render"
 	"html/template"
-	"log"
+	"math"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 )
 
 const ANY = "ANY"
 
 type HandlerFunc func(ctx *Context)
 
+// MiddlewareFunc 层层包装 HandlerFunc 的中间件，后添加的在外层先执行
 type MiddlewareFunc func(handlerFunc HandlerFunc) HandlerFunc
 
+// HandlersChain 路由的处理函数链，依次是全局、路由级别 MiddlewareFunc、父分组、子分组、Handle 传入的中间件，最后是业务处理函数
+type HandlersChain []HandlerFunc
+
+// abortIndex 调用 Abort 后 index 设置成这个值，处理函数链中剩下的函数不再执行
+const abortIndex = math.MaxInt32
+
+// Handler 将 MiddlewareFunc 转换成处理函数链中的 HandlerFunc，next 对应 ctx.Next()
+func (m MiddlewareFunc) Handler() HandlerFunc {
+	h := m(func(ctx *Context) {
+		ctx.Next()
+	})
+	return func(ctx *Context) {
+		h(ctx)
+		// 没有调用 next，或者 next 中的 panic 被中间件恢复了，剩下的处理函数不再执行
+		if ctx.index < len(ctx.handlers) {
+			ctx.Abort()
+		}
+	}
+}
+
+// adaptMiddlewares 倒序转换 MiddlewareFunc，和层层包装一样，后面的中间件在外层先执行
+func adaptMiddlewares(middlewares []MiddlewareFunc) HandlersChain {
+	handlers := make(HandlersChain, len(middlewares))
+	for i, m := range middlewares {
+		handlers[len(middlewares)-1-i] = m.Handler()
+	}
+	return handlers
+}
+
 type routerGroup struct {
-	groupName          string
-	handlerFuncMap     map[string]map[string]HandlerFunc
-	middlewaresFuncMap map[string]map[string][]MiddlewareFunc
-	handlerMethodMap   map[string][]string //支持不同的路由请求
-	treeNode           *treeNode
-	middlewares        []MiddlewareFunc
+	engine      *Engine
+	parent      *routerGroup // 父分组，为 nil 表示是根分组
+	groupName   string       // 完整的路径前缀，如 /api/v1，根分组为空
+	host        string       // 分组所属的域名，为空表示不区分域名
+	tree        *treeNode    // 分组的路由注册到的路由树，每个域名一棵
+	middlewares HandlersChain
+}
+
+// Route 注册的一条路由，同一路径不同的请求方法对应不同的 Route，Get、Post 等方法返回它用于设置名称
+type Route struct {
+	name        string // 路由名称，通过 Name 设置
+	method      string
+	path        string // 包含分组前缀的完整路径
+	handler     HandlerFunc
+	wrappers    HandlersChain // Get、Post 等传入的 MiddlewareFunc，在分组的中间件之前执行
+	middlewares HandlersChain // Handle 传入的中间件，在分组的中间件之后执行
+	group       *routerGroup
+	handlers    HandlersChain // 预先拼接好的处理函数链，中间件变化时重新计算
 }
 
+// router 所有分组共用根分组的路由树，按完整路径注册，通过 Host 注册的域名各自有一棵路由树
 type router struct {
-	groups []*routerGroup
-	engine *Engine
+	routerGroup // 根分组，直接注册在 Engine 上的路由，如 / 和 /healthz
+	groups      []*routerGroup
+	routes      []*Route // 按注册顺序记录所有路由
+	hosts       []*hostRouter
 }
 
 type ErrorHandler func(err error) (int, any)
@@ -39,92 +85,196 @@ type Engine struct {
 	HTMLRender   render.HTMLRender
 	pool         sync.Pool
 	Logger       *msLog.Logger
-	middles      []MiddlewareFunc
+	middles      HandlersChain
 	errorHandler ErrorHandler
+	noRoute      []HandlerFunc
+	noMethod     []HandlerFunc
+	namedRoutes  map[string]*Route
+	// noRouteHandlers、noMethodHandlers 和 optionsHandlers 是经过全局中间件的 404、405 和自动 OPTIONS 响应的处理函数链
+	noRouteHandlers  HandlersChain
+	noMethodHandlers HandlersChain
+	optionsHandlers  HandlersChain
+	// requestHooks 和 responseHooks 是 OnRequest、OnResponse 注册的每个请求都执行的函数
+	requestHooks  []HandlerFunc
+	responseHooks []HandlerFunc
+	// StrictRouting 开启后，注册路由时同一位置上的参数和 ** 同时存在也会 panic
+	StrictRouting bool
+	// RedirectTrailingSlash 开启后，/user/hello/ 没有匹配到路由但 /user/hello 可以匹配时重定向过去，反之亦然
+	RedirectTrailingSlash bool
+	// RedirectFixedPath 开启后，没有匹配到路由时先清理路径（//user/../user/hello -> /user/hello），
+	// 再忽略大小写查找路由，找到了就重定向过去
+	RedirectFixedPath bool
+	// PrintRoutes 开启后启动时通过 Logger 以 Debug 级别打印路由表
+	PrintRoutes bool
+	// ReadTimeout、WriteTimeout、IdleTimeout、ReadHeaderTimeout 设置到 Run 系列方法使用的 http.Server 上，0 表示不超时
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	// ShutdownTimeout RunWithGracefulShutdown 收到退出信号后等待请求处理完成的最长时间，
+	// 也是 OnShutdown 注册的函数的超时时间，0 表示使用默认的 10 秒
+	ShutdownTimeout time.Duration
+	// UseH2C 开启后 Run、RunListener 等不使用 TLS 的方法也支持 HTTP/2 明文（h2c），
+	// 包括直接发送 HTTP/2 连接前言（prior knowledge）和通过 Upgrade: h2c 升级两种方式
+	UseH2C bool
+	serverState
 }
 
+// Use 给分组添加中间件，后添加的在外层先执行，并且在 UseHandlers 添加的中间件之前执行；
+// 父分组的中间件在子分组的之前，Get、Post 等传入的路由中间件在分组的中间件之前执行
 func (r *routerGroup) Use(middlewares ...MiddlewareFunc) {
-	r.middlewares = append(r.middlewares, middlewares...)
+	r.middlewares = append(adaptMiddlewares(middlewares), r.middlewares...)
+	r.engine.rebuildHandlers()
 }
 
-func (r *routerGroup) methodHandle(name string, method string, h HandlerFunc, ctx *Context) {
-	//前置通用中间件
-	if r.middlewares != nil {
-		for _, middlewareFunc := range r.middlewares {
-			h = middlewareFunc(h)
-		}
+// UseHandlers 给分组添加 HandlerFunc 形式的中间件，中间件中调用 ctx.Next() 执行后面的处理函数，
+// 调用 ctx.Abort() 或者不调用 ctx.Next() 都会中止后面的处理函数
+func (r *routerGroup) UseHandlers(handlers ...HandlerFunc) {
+	r.middlewares = append(r.middlewares, handlers...)
+	r.engine.rebuildHandlers()
+}
+
+// buildHandlers 拼接路由的处理函数链，全局中间件在最前面，然后是路由级别的 MiddlewareFunc，
+// 再是父分组、子分组的中间件，最后是 Handle 传入的中间件
+func (rt *Route) buildHandlers() HandlersChain {
+	var groups []*routerGroup
+	middles := rt.group.engine.middles
+	size := len(middles) + len(rt.wrappers) + len(rt.middlewares) + 1
+	for g := rt.group; g != nil; g = g.parent {
+		groups = append(groups, g)
+		size += len(g.middlewares)
 	}
-	//组路由级别
-	middlewareFuncs := r.middlewaresFuncMap[name][method]
-	if middlewareFuncs != nil {
-		for _, middlewareFunc := range middlewareFuncs {
-			h = middlewareFunc(h)
-		}
+	handlers := make(HandlersChain, 0, size)
+	handlers = append(handlers, middles...)
+	handlers = append(handlers, rt.wrappers...)
+	for i := len(groups) - 1; i >= 0; i-- {
+		handlers = append(handlers, groups[i].middlewares...)
 	}
-	h(ctx)
+	handlers = append(handlers, rt.middlewares...)
+	return append(handlers, rt.handler)
 }
 
-func (r *routerGroup) handle(name string, method string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	_, ok := r.handlerFuncMap[name]
-	if !ok {
-		r.handlerFuncMap[name] = make(map[string]HandlerFunc)
-		r.middlewaresFuncMap[name] = make(map[string][]MiddlewareFunc)
+func (r *routerGroup) handle(name string, method string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.addRoute(name, method, handlerFunc, adaptMiddlewares(middlewareFunc), nil)
+}
+
+func (r *routerGroup) addRoute(name string, method string, handlerFunc HandlerFunc, wrappers HandlersChain, middlewares HandlersChain) *Route {
+	path := joinPaths(r.groupName, name)
+	node := r.tree.Put(path, r.engine.StrictRouting)
+	if _, ok := node.routes[method]; ok {
+		panic(fmt.Sprintf("msgo: route %s %s is already registered", method, path))
+	}
+	if node.routes == nil {
+		node.routes = make(map[string]*Route)
 	}
-	r.handlerFuncMap[name][method] = handlerFunc
+	rt := &Route{
+		method:      method,
+		path:        path,
+		handler:     handlerFunc,
+		wrappers:    wrappers,
+		middlewares: middlewares,
+		group:       r,
+	}
+	rt.handlers = rt.buildHandlers()
+	node.routes[method] = rt
+	r.engine.routes = append(r.engine.routes, rt)
+	return rt
+}
 
-	r.handlerMethodMap[method] = append(r.handlerMethodMap[method], name)
-	r.middlewaresFuncMap[name][method] = append(r.middlewaresFuncMap[name][method], middlewareFunc...)
-	r.treeNode.Put(name)
+// Handle 注册 HandlerFunc 形式的处理函数链，最后一个是业务处理函数，前面的是路由级别的中间件
+// g.Handle(http.MethodGet, "/info", auth, info)
+func (r *routerGroup) Handle(method string, name string, handlers ...HandlerFunc) *Route {
+	if len(handlers) == 0 {
+		panic(fmt.Sprintf("msgo: route %s %s has no handler", method, joinPaths(r.groupName, name)))
+	}
+	last := len(handlers) - 1
+	return r.addRoute(name, method, handlers[last], nil, handlers[:last:last])
+}
+
+// Name 给路由起一个名字，用于 Engine.URL 和模板中的 url 函数生成路径
+// g.Get("/get/:id", h).Name("order.get")
+func (rt *Route) Name(name string) *Route {
+	e := rt.group.engine
+	if old, ok := e.namedRoutes[name]; ok {
+		panic(fmt.Sprintf("msgo: route name '%s' of %s %s is already used by %s %s", name, rt.method, rt.path, old.method, old.path))
+	}
+	if e.namedRoutes == nil {
+		e.namedRoutes = make(map[string]*Route)
+	}
+	rt.name = name
+	e.namedRoutes[name] = rt
+	return rt
+}
+
+// joinPaths 拼接分组前缀和路由 /api/v1 + /users -> /api/v1/users
+func joinPaths(prefix string, name string) string {
+	if name == "" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	if name[0] != '/' {
+		name = "/" + name
+	}
+	return prefix + name
 }
 
-func (r *routerGroup) Any(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, "ANY", handlerFunc, middlewareFunc...)
+func (r *routerGroup) Any(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, "ANY", handlerFunc, middlewareFunc...)
 }
 
-func (r *routerGroup) Get(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodGet, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Get(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodGet, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Post(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPost, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Post(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPost, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Delete(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodDelete, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Delete(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodDelete, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Put(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPut, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Put(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPut, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Patch(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPatch, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Patch(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPatch, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Options(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodOptions, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Options(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodOptions, handlerFunc, middlewareFunc...)
 }
-func (r *routerGroup) Head(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodHead, handlerFunc, middlewareFunc...)
+func (r *routerGroup) Head(name string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodHead, handlerFunc, middlewareFunc...)
 }
 
-func (r *router) Group(name string) *routerGroup {
+// Group 创建分组，如 engine.Group("api") 对应 /api，api.Group("v1") 对应 /api/v1
+// 子分组继承父分组的路径前缀和中间件，子分组 Use 的中间件只对子分组生效
+func (r *routerGroup) Group(name string) *routerGroup {
+	groupName := r.groupName
+	if name = strings.Trim(name, "/"); name != "" {
+		groupName += "/" + name
+	}
 	g := &routerGroup{
-		groupName:          name,
-		handlerFuncMap:     make(map[string]map[string]HandlerFunc),
-		middlewaresFuncMap: make(map[string]map[string][]MiddlewareFunc),
-		handlerMethodMap:   make(map[string][]string),
-		treeNode:           &treeNode{name: "/", children: make([]*treeNode, 0)},
-	}
-	g.Use(r.engine.middles...)
-	r.groups = append(r.groups, g)
+		engine:    r.engine,
+		parent:    r,
+		groupName: groupName,
+		host:      r.host,
+		tree:      r.tree,
+	}
+	r.engine.groups = append(r.engine.groups, g)
 	return g
 }
 
 func New() *Engine {
 	engine := &Engine{
-		router:     &router{},
+		router:     &router{routerGroup: routerGroup{tree: &treeNode{}}},
 		HTMLRender: render.HTMLRender{},
 		Logger:     msLog.Default(),
 	}
+	engine.router.engine = engine
 	engine.pool.New = func() any {
 		return engine.allocateContext()
 	}
+	engine.rebuildHandlers()
 	return engine
 }
 
@@ -133,12 +283,11 @@ func Default() *Engine {
 	engine.Logger = msLog.Default()
 	// 这是两个默认的行为，默认是需要实现的
 	engine.Use(Recovery, Logging)
-	engine.router.engine = engine
 	return engine
 }
 
 func (e *Engine) allocateContext() any {
-	return &Context{engine: e}
+	return &Context{engine: e, params: make(Params, 0, e.maxParams())}
 }
 
 func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
@@ -147,7 +296,7 @@ func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
 
 // LoadTemplate 以 LoadTemplateGlob 方式加载所有模板
 func (e *Engine) LoadTemplate(pattern string) {
-	t := template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern))
+	t := template.Must(template.New("").Funcs(e.FuncMap()).ParseGlob(pattern))
 	e.SetHtmlTemplate(t)
 }
 
@@ -157,37 +306,133 @@ func (e *Engine) SetHtmlTemplate(t *template.Template) {
 
 func (e *Engine) httpRequestHandle(ctx *Context) {
 	method := ctx.R.Method
-	for _, group := range e.groups {
-		routerName := SubStringLast(ctx.R.URL.Path, "/"+group.groupName)
-		node := group.treeNode.Get(routerName)
-		if node != nil && node.isEnd {
-			// 路由匹配上了
-			if handler, ok := group.handlerFuncMap[node.routerName][ANY]; ok {
-				group.methodHandle(node.routerName, ANY, handler, ctx)
-				return
+	tree, params := e.hostTree(ctx.R.Host, ctx.params[:0])
+	ctx.params = params
+	match, ok := tree.match(ctx.R.URL.Path, params)
+	if !ok && tree != e.tree {
+		// 域名分组中没有这个路径时使用 Engine 上注册的路由，域名参数仍然可以获取
+		match, ok = e.tree.match(ctx.R.URL.Path, params)
+	}
+	if ok {
+		// 路由匹配上了
+		ctx.params = match.params
+		if rt, noBody := match.route(method); rt != nil {
+			ctx.writer.noBody = noBody
+			ctx.handle(rt.handlers)
+			return
+		}
+		ctx.W.Header().Set("Allow", match.allow())
+		if method == http.MethodOptions {
+			ctx.handle(e.optionsHandlers)
+			return
+		}
+		ctx.handle(e.noMethodHandlers)
+		return
+	}
+	location, ok := e.redirectPath(ctx, tree)
+	if !ok && tree != e.tree {
+		location, ok = e.redirectPath(ctx, e.tree)
+	}
+	if ok {
+		ctx.handle(e.combineHandlers(nil, func(ctx *Context) {
+			code := http.StatusMovedPermanently
+			if ctx.R.Method != http.MethodGet {
+				code = http.StatusPermanentRedirect
 			}
-
-			if handler, ok := group.handlerFuncMap[node.routerName][ctx.R.Method]; ok {
-				group.methodHandle(node.routerName, ctx.R.Method, handler, ctx)
-				return
+			if ctx.R.URL.RawQuery != "" {
+				location += "?" + ctx.R.URL.RawQuery
 			}
-			ctx.W.WriteHeader(http.StatusMethodNotAllowed)
-			fmt.Fprintf(ctx.W, "%s %s not allowed \n", ctx.R.RequestURI, method)
-			return
+			ctx.StatusCode = code
+			http.Redirect(ctx.W, ctx.R, location, code)
+		}))
+		return
+	}
+	ctx.handle(e.noRouteHandlers)
+}
+
+// redirectPath 没有匹配到路由时，根据 RedirectTrailingSlash 和 RedirectFixedPath 查找可以重定向的路由
+func (e *Engine) redirectPath(ctx *Context, tree *treeNode) (string, bool) {
+	path := ctx.R.URL.Path
+	if ctx.R.Method == http.MethodConnect || path == "/" {
+		return "", false
+	}
+	if e.RedirectTrailingSlash {
+		location := path + "/"
+		if strings.HasSuffix(path, "/") {
+			location = path[:len(path)-1]
+		}
+		if canHandle(ctx, tree, location) {
+			return location, true
+		}
+	}
+	if e.RedirectFixedPath {
+		location, ok := tree.findCaseInsensitive(cleanPath(path), e.RedirectTrailingSlash)
+		if ok && location != path && canHandle(ctx, tree, location) {
+			return location, true
 		}
 	}
-	ctx.W.WriteHeader(http.StatusNotFound)
-	fmt.Fprintf(ctx.W, "%s  not found \n", ctx.R.RequestURI)
+	return "", false
+}
+
+// canHandle 重定向的目标路径上有处理该请求方法的路由，否则重定向过去也只会得到 405
+func canHandle(ctx *Context, tree *treeNode, location string) bool {
+	match, ok := tree.match(location, ctx.params)
+	if !ok {
+		return false
+	}
+	rt, _ := match.route(ctx.R.Method)
+	return rt != nil
+}
+
+// combineHandlers 在全局中间件后面拼接 handlers，没有注册 handlers 时使用 defaultHandler
+func (e *Engine) combineHandlers(handlers []HandlerFunc, defaultHandler HandlerFunc) HandlersChain {
+	if len(handlers) == 0 {
+		handlers = []HandlerFunc{defaultHandler}
+	}
+	chain := make(HandlersChain, 0, len(e.middles)+len(handlers))
+	chain = append(chain, e.middles...)
+	return append(chain, handlers...)
+}
+
+// rebuildHandlers 中间件变化后重新拼接所有路由以及 404、405 的处理函数链
+func (e *Engine) rebuildHandlers() {
+	for _, rt := range e.routes {
+		rt.handlers = rt.buildHandlers()
+	}
+	e.noRouteHandlers = e.combineHandlers(e.noRoute, defaultNoRoute)
+	e.noMethodHandlers = e.combineHandlers(e.noMethod, defaultNoMethod)
+	e.optionsHandlers = e.combineHandlers(nil, defaultOptions)
+}
+
+func defaultNoRoute(ctx *Context) {
+	ctx.String(http.StatusNotFound, "%s  not found \n", ctx.R.RequestURI)
+}
+
+// defaultOptions 没有注册 OPTIONS 时自动返回 204，Allow 响应头在匹配路由时已经设置
+func defaultOptions(ctx *Context) {
+	ctx.StatusCode = http.StatusNoContent
+	ctx.W.WriteHeader(http.StatusNoContent)
+}
+
+func defaultNoMethod(ctx *Context) {
+	ctx.String(http.StatusMethodNotAllowed, "%s %s not allowed \n", ctx.R.RequestURI, ctx.R.Method)
 }
 
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := e.pool.Get().(*Context)
-	ctx.W = w
-	ctx.R = r
-	ctx.Logger = e.Logger
+	ctx.reset(w, r)
+	for _, hook := range e.requestHooks {
+		hook(ctx)
+	}
 	e.httpRequestHandle(ctx)
+	for _, hook := range e.responseHooks {
+		hook(ctx)
+	}
+	// 处理函数只调用了 WriteHeader 没有写响应体时，在这里发送响应头
+	ctx.writer.WriteHeaderNow()
+	// 放回 pool 之前清空请求内的数据，避免被下一个请求读到
+	ctx.keys = nil
 	e.pool.Put(ctx)
-
 }
 
 func SubStringLast(str string, substr string) string {
@@ -199,22 +444,39 @@ func SubStringLast(str string, substr string) string {
 	return str[index+len(substr):]
 }
 
-func (e *Engine) Run() {
-	//groups := e.router.groups
-	//for _, g := range groups {
-	//	for name, handle := range g.handlerFuncMap {
-	//		http.HandleFunc("/"+g.groupName+name, handle)
-	//	}
-	//}
-	http.Handle("/", e)
-	err := http.ListenAndServe(":8111", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
+// Use 添加全局中间件，多次调用会累加，对所有路由以及 404、405 都生效，与路由的注册顺序无关，
+// 全局中间件在其他中间件之前执行，和分组的 Use 一样后添加的在外层，并且在 UseHandlers 添加的之前
+func (e *Engine) Use(middles ...MiddlewareFunc) {
+	e.middles = append(adaptMiddlewares(middles), e.middles...)
+	e.rebuildHandlers()
 }
 
-func (e *Engine) Use(middles ...MiddlewareFunc) {
-	e.middles = middles
+// UseHandlers 添加 HandlerFunc 形式的全局中间件
+func (e *Engine) UseHandlers(handlers ...HandlerFunc) {
+	e.middles = append(e.middles, handlers...)
+	e.rebuildHandlers()
+}
+
+// OnRequest 注册每个请求开始时执行的函数，在路由匹配和中间件之前执行，如记录请求开始时间
+func (e *Engine) OnRequest(hooks ...HandlerFunc) {
+	e.requestHooks = append(e.requestHooks, hooks...)
+}
+
+// OnResponse 注册每个请求处理完之后执行的函数，可以通过 ctx.W.Status() 获取响应的状态码，如上报监控指标
+func (e *Engine) OnResponse(hooks ...HandlerFunc) {
+	e.responseHooks = append(e.responseHooks, hooks...)
+}
+
+// NoRoute 没有匹配到路由时执行的处理函数，默认返回 404
+func (e *Engine) NoRoute(handlers ...HandlerFunc) {
+	e.noRoute = handlers
+	e.rebuildHandlers()
+}
+
+// NoMethod 路由匹配上但请求方法不支持时执行的处理函数，默认返回 405
+func (e *Engine) NoMethod(handlers ...HandlerFunc) {
+	e.noMethod = handlers
+	e.rebuildHandlers()
 }
 
 func (e *Engine) RegisterErrorHandler(handler ErrorHandler) {
@@ -0,0 +1,1453 @@
+package msgo
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	msLog "github.com/H-kang-better/msgo/log"
+	"golang.org/x/net/http2"
+	"html/template"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// TestEngineConcurrentServeHTTP 并发请求下路由匹配不能串到其他路由，配合 go test -race 使用
+func TestEngineConcurrentServeHTTP(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Get("/get/:id", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "get %s", ctx.Param("id"))
+	})
+	g.Get("/list/:page/:size", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "list %s %s", ctx.Param("page"), ctx.Param("size"))
+	})
+	g.Get("/static/**", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "static %s", ctx.CatchAll())
+	})
+	g.Post("/get/:id", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "post %s", ctx.Param("id"))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				tests := []struct {
+					method string
+					path   string
+					want   string
+				}{
+					{http.MethodGet, fmt.Sprintf("/user/get/%d", j), fmt.Sprintf("get %d", j)},
+					{http.MethodGet, fmt.Sprintf("/user/list/%d/%d", i, j), fmt.Sprintf("list %d %d", i, j)},
+					{http.MethodGet, fmt.Sprintf("/user/static/%d/%d.css", i, j), fmt.Sprintf("static %d/%d.css", i, j)},
+					{http.MethodPost, fmt.Sprintf("/user/get/%d", i), fmt.Sprintf("post %d", i)},
+				}
+				for _, tt := range tests {
+					w := httptest.NewRecorder()
+					engine.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+					if got := w.Body.String(); got != tt.want {
+						t.Errorf("%s %s: got %q, want %q", tt.method, tt.path, got, tt.want)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestEngineServeHTTPNotFound(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Get("/get/:id", func(ctx *Context) {})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/user/get/1", http.StatusOK},
+		{http.MethodPost, "/user/get/1", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/user/get", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, w.Code, tt.code)
+		}
+	}
+}
+
+func TestEngineDuplicateRoute(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Get("/get/:id", func(ctx *Context) {})
+	g.Post("/get/:id", func(ctx *Context) {})
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("registering a duplicate route should panic")
+		}
+	}()
+	g.Get("/get/:id", func(ctx *Context) {})
+}
+
+func TestEngineParamConstraint(t *testing.T) {
+	engine := New()
+	g := engine.Group("article")
+	g.Get("/:id<int>", func(ctx *Context) {
+		id, err := ctx.ParamInt("id")
+		if err != nil {
+			t.Error(err)
+		}
+		fmt.Fprintf(ctx.W, "%d", id+1)
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/article/41", nil))
+	if w.Body.String() != "42" {
+		t.Errorf("GET /article/41: got %q, want %q", w.Body.String(), "42")
+	}
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/article/abc", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /article/abc: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestRouterGroupNested(t *testing.T) {
+	engine := New()
+	var calls []string
+	record := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) {
+				calls = append(calls, name)
+				next(ctx)
+			}
+		}
+	}
+	api := engine.Group("api")
+	api.Use(record("api"))
+	v1 := api.Group("v1")
+	// 同一分组后添加的中间件在外层，路由级别的中间件在分组的中间件之前
+	v1.Use(record("v1a"), record("v1b"))
+	v2 := api.Group("/v2/")
+	v1.Get("/users/:id", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "v1 %s", ctx.Param("id"))
+	}, record("route1"), record("route2"))
+	v2.Get("/users/:id", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "v2 %s", ctx.Param("id"))
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/1", nil))
+	if w.Body.String() != "v1 1" {
+		t.Errorf("GET /api/v1/users/1: got %q", w.Body.String())
+	}
+	if fmt.Sprint(calls) != "[route2 route1 api v1b v1a]" {
+		t.Errorf("middlewares called %v, want [route2 route1 api v1b v1a]", calls)
+	}
+
+	calls = nil
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/users/2", nil))
+	if w.Body.String() != "v2 2" {
+		t.Errorf("GET /api/v2/users/2: got %q", w.Body.String())
+	}
+	if fmt.Sprint(calls) != "[api]" {
+		t.Errorf("middlewares called %v, want [api]", calls)
+	}
+}
+
+func TestEngineGroupPrefix(t *testing.T) {
+	engine := New()
+	engine.Get("/", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "index")
+	})
+	engine.Get("/healthz", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "ok")
+	})
+	user := engine.Group("user")
+	user.Get("/:name", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "user %s", ctx.Param("name"))
+	})
+	admin := engine.Group("admin")
+	admin.Get("/user/:name", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "admin %s", ctx.Param("name"))
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/", http.StatusOK, "index"},
+		{"/healthz", http.StatusOK, "ok"},
+		{"/user/tom", http.StatusOK, "user tom"},
+		{"/admin/user/tom", http.StatusOK, "admin tom"},
+		{"/users/tom", http.StatusNotFound, ""},
+		{"/x/user/tom", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("GET %s: status %d, want %d", tt.path, w.Code, tt.code)
+		}
+		if tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("GET %s: got %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestEngineMethods(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Get("/info", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "get info")
+	})
+	g.Post("/info", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "post info")
+	})
+	g.Any("/any", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "any")
+	})
+	g.Delete("/any", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "delete any")
+	})
+	g.Get("/any", func(ctx *Context) {
+		ctx.W.Header().Set("X-Handler", "get")
+		fmt.Fprint(ctx.W, "get any")
+	})
+	// 自动的 OPTIONS 响应也经过全局中间件，CORS 中间件可以处理预检请求
+	engine.UseHandlers(func(ctx *Context) {
+		ctx.W.Header().Set("Access-Control-Allow-Origin", "*")
+		ctx.Next()
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/user/info", http.StatusOK, "get info", ""},
+		{http.MethodHead, "/user/info", http.StatusOK, "", ""},
+		{http.MethodOptions, "/user/info", http.StatusNoContent, "", "GET, HEAD, OPTIONS, POST"},
+		{http.MethodPut, "/user/info", http.StatusMethodNotAllowed, "/user/info PUT not allowed \n", "GET, HEAD, OPTIONS, POST"},
+		{http.MethodPut, "/user/any", http.StatusOK, "any", ""},
+		{http.MethodDelete, "/user/any", http.StatusOK, "delete any", ""},
+		{http.MethodHead, "/user/any", http.StatusOK, "", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, w.Code, tt.code)
+		}
+		if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("%s %s: global middleware not applied", tt.method, tt.path)
+		}
+		// HEAD 优先使用 GET 的处理函数，而不是 ANY
+		if tt.method == http.MethodHead && tt.path == "/user/any" && w.Header().Get("X-Handler") != "get" {
+			t.Errorf("HEAD /user/any: not handled by GET")
+		}
+		if w.Body.String() != tt.body {
+			t.Errorf("%s %s: got %q, want %q", tt.method, tt.path, w.Body.String(), tt.body)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow %q, want %q", tt.method, tt.path, allow, tt.allow)
+		}
+	}
+}
+
+func TestEngineNoRoute(t *testing.T) {
+	engine := New()
+	var codes []int
+	engine.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			codes = append(codes, ctx.StatusCode)
+		}
+	})
+	g := engine.Group("user")
+	g.Get("/info", func(ctx *Context) {})
+	engine.NoRoute(func(ctx *Context) {
+		ctx.JSON(http.StatusNotFound, map[string]string{"msg": "not found"})
+	})
+	engine.NoMethod(func(ctx *Context) {
+		ctx.JSON(http.StatusMethodNotAllowed, map[string]string{"msg": "method not allowed"})
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/none", nil))
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"msg":"not found"}` {
+		t.Errorf("GET /user/none: %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/user/info", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != `{"msg":"method not allowed"}` {
+		t.Errorf("POST /user/info: %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Allow") == "" {
+		t.Error("POST /user/info: missing Allow header")
+	}
+	if fmt.Sprint(codes) != "[404 405]" {
+		t.Errorf("middleware saw status %v, want [404 405]", codes)
+	}
+}
+
+func TestEngineRedirectPath(t *testing.T) {
+	engine := New()
+	engine.RedirectTrailingSlash = true
+	engine.RedirectFixedPath = true
+	g := engine.Group("user")
+	g.Get("/hello", func(ctx *Context) {})
+	g.Get("/list/", func(ctx *Context) {})
+	g.Post("/get/:id", func(ctx *Context) {})
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/user/hello", http.StatusOK, ""},
+		{http.MethodGet, "/user/hello/", http.StatusMovedPermanently, "/user/hello"},
+		{http.MethodGet, "/user/list", http.StatusMovedPermanently, "/user/list/"},
+		{http.MethodGet, "/user/hello/?a=1", http.StatusMovedPermanently, "/user/hello?a=1"},
+		{http.MethodGet, "//user/hello", http.StatusMovedPermanently, "/user/hello"},
+		{http.MethodGet, "/user/../user/hello", http.StatusMovedPermanently, "/user/hello"},
+		{http.MethodGet, "/USER/Hello/", http.StatusMovedPermanently, "/user/hello"},
+		{http.MethodPost, "/User/GET/Ab", http.StatusPermanentRedirect, "/user/get/Ab"},
+		{http.MethodGet, "/user/none", http.StatusNotFound, ""},
+		// 目标路径上没有对应请求方法的路由时不重定向
+		{http.MethodPost, "/user/hello/", http.StatusNotFound, ""},
+		{http.MethodGet, "/User/GET/Ab", http.StatusNotFound, ""},
+		{http.MethodHead, "/user/hello/", http.StatusPermanentRedirect, "/user/hello"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tt.method, "/", nil)
+		r.URL.Path, r.URL.RawQuery, _ = strings.Cut(tt.path, "?")
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, w.Code, tt.code)
+		}
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s %s: Location %q, want %q", tt.method, tt.path, location, tt.location)
+		}
+	}
+
+	engine.RedirectTrailingSlash = false
+	engine.RedirectFixedPath = false
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/hello/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /user/hello/ without redirect: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestEngineURL(t *testing.T) {
+	engine := New()
+	order := engine.Group("order")
+	order.Get("/get/:id", func(ctx *Context) {}).Name("order.get")
+	order.Get("/file/{name:[a-z ]+}/**", func(ctx *Context) {}).Name("order.file")
+	var rt *Route = engine.Host(":tenant.example.com").Get("/u/:id", func(ctx *Context) {})
+	rt.Name("tenant.user")
+
+	tests := []struct {
+		name   string
+		params map[string]any
+		want   string
+	}{
+		{"order.get", map[string]any{"id": 12}, "/order/get/12"},
+		{"order.get", map[string]any{"id": "a/b", "page": 2, "q": "x y"}, "/order/get/a%2Fb?page=2&q=x+y"},
+		{"order.file", map[string]any{"name": "my file", "**": "css/a b.css"}, "/order/file/my%20file/css/a%20b.css"},
+		// 只生成路径，域名参数不会出现在查询参数中
+		{"tenant.user", map[string]any{"tenant": "acme", "id": 1}, "/u/1"},
+	}
+	for _, tt := range tests {
+		got, err := engine.URL(tt.name, tt.params)
+		if err != nil || got != tt.want {
+			t.Errorf("URL(%s, %v) = %q, %v, want %q", tt.name, tt.params, got, err, tt.want)
+		}
+	}
+	if _, err := engine.URL("order.get", nil); err == nil {
+		t.Error("URL without required param should fail")
+	}
+	if _, err := engine.URL("order.none", nil); err == nil {
+		t.Error("URL of unknown route should fail")
+	}
+
+	// 自己解析的模板通过 FuncMap 使用 url 以及 SetFuncMap 设置的函数
+	engine.SetFuncMap(template.FuncMap{"upper": strings.ToUpper})
+	engine.SetHtmlTemplate(template.Must(template.New("link").Funcs(engine.FuncMap()).Parse(`<a href="{{url "order.get" "id" 7}}">{{upper "go"}}</a>`)))
+	engine.Get("/link", func(ctx *Context) {
+		ctx.Template("link", nil)
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/link", nil))
+	if w.Body.String() != `<a href="/order/get/7">GO</a>` {
+		t.Errorf("template url = %q", w.Body.String())
+	}
+}
+
+func handleUserInfo(ctx *Context) {}
+
+func TestEngineRoutes(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Use(Recovery)
+	g.Get("/info/:id", handleUserInfo, Logging).Name("user.info")
+	g.Post("/info/:id", handleUserInfo)
+	engine.Get("/debug/routes", engine.RoutesHandler())
+
+	want := []RouteInfo{
+		{"", http.MethodGet, "/user/info/:id", "user.info", "github.com/H-kang-better/msgo.handleUserInfo", 2},
+		{"", http.MethodPost, "/user/info/:id", "", "github.com/H-kang-better/msgo.handleUserInfo", 1},
+	}
+	routes := engine.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("Routes() returned %d routes, want 3", len(routes))
+	}
+	for i, rt := range want {
+		if routes[i] != rt {
+			t.Errorf("Routes()[%d] = %+v, want %+v", i, routes[i], rt)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	if !strings.Contains(w.Body.String(), `"path":"/user/info/:id"`) {
+		t.Errorf("GET /debug/routes: got %s", w.Body.String())
+	}
+
+	// 只有开启 PrintRoutes 时才打印路由表
+	logFile, err := os.CreateTemp(t.TempDir(), "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	engine.Logger = msLog.Default()
+	engine.Logger.Outs = []*msLog.LoggerWriter{{Level: -1, Out: logFile}}
+	engine.printRoutes()
+	if logs, _ := os.ReadFile(logFile.Name()); len(logs) != 0 {
+		t.Errorf("route table printed without PrintRoutes: %q", logs)
+	}
+	engine.PrintRoutes = true
+	engine.printRoutes()
+	if logs, _ := os.ReadFile(logFile.Name()); !strings.Contains(string(logs), "/user/info/:id") {
+		t.Errorf("route table not printed: %q", logs)
+	}
+
+	// 默认的 Logger 输出到控制台，每个路由只打印一次
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	oldStdout := os.Stdout
+	os.Stdout = stdout
+	engine.Logger = msLog.Default()
+	engine.printRoutes()
+	os.Stdout = oldStdout
+	if logs, _ := os.ReadFile(stdout.Name()); strings.Count(string(logs), "/debug/routes") != 1 {
+		t.Errorf("route printed %d times to stdout: %q", strings.Count(string(logs), "/debug/routes"), logs)
+	}
+}
+
+func TestEngineHost(t *testing.T) {
+	engine := New()
+	engine.Get("/", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "default")
+	})
+	engine.Group("g").Get("/:name", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "%s %s", ctx.Param("tenant"), ctx.Param("name"))
+	})
+	admin := engine.Host("admin.example.com")
+	admin.Get("/", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "admin")
+	})
+	tenant := engine.Host(":tenant.example.com")
+	tenant.Group("user").Get("/:id", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "%s %s", ctx.Param("tenant"), ctx.Param("id"))
+	})
+
+	tests := []struct {
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"example.com", "/", http.StatusOK, "default"},
+		{"admin.example.com", "/", http.StatusOK, "admin"},
+		{"ADMIN.example.com:8111", "/", http.StatusOK, "admin"},
+		{"acme.example.com:8111", "/user/7", http.StatusOK, "acme 7"},
+		// 域名分组中没有的路径使用 Engine 上注册的路由
+		{"acme.example.com", "/", http.StatusOK, "default"},
+		{"acme.example.com", "/g/r", http.StatusOK, "acme r"},
+		{"admin.example.com", "/g/r", http.StatusOK, " r"},
+		{"acme.example.com", "/none", http.StatusNotFound, ""},
+		{"a.acme.example.com", "/user/7", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Host = tt.host
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%s%s: status %d, want %d", tt.host, tt.path, w.Code, tt.code)
+		}
+		if tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("%s%s: got %q, want %q", tt.host, tt.path, w.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestRouterGroupStatic(t *testing.T) {
+	fsys := fstest.MapFS{
+		"css/main.css":    {Data: []byte("body{}")},
+		"css/main.css.gz": {Data: []byte("gzipped")},
+		"docs/index.html": {Data: []byte("<h1>docs</h1>")},
+		"img/logo.png":    {Data: []byte("png")},
+		"favicon.ico":     {Data: []byte("ico")},
+	}
+	engine := New()
+	engine.StaticFS("/static", EmbedFS(fsys, "."), StaticConfig{
+		Browse:        true,
+		CacheControl:  "public, max-age=3600",
+		Precompressed: true,
+	})
+	engine.StaticFS("/private", http.FS(fsys))
+	engine.StaticFileFS("/favicon.ico", "favicon.ico", http.FS(fsys))
+
+	tests := []struct {
+		path     string
+		gzip     bool
+		code     int
+		body     string
+		encoding string
+	}{
+		{"/static/css/main.css", false, http.StatusOK, "body{}", ""},
+		{"/static/css/main.css", true, http.StatusOK, "gzipped", "gzip"},
+		{"/static/docs/", false, http.StatusOK, "<h1>docs</h1>", ""},
+		{"/static/docs", false, http.StatusMovedPermanently, "", ""},
+		{"/static/img/", false, http.StatusOK, "<pre>\n<a href=\"logo.png\">logo.png</a>\n</pre>\n", ""},
+		{"/static/none.css", false, http.StatusNotFound, "", ""},
+		{"/static/../ms.go", false, http.StatusNotFound, "", ""},
+		{"/private/img/", false, http.StatusNotFound, "", ""},
+		{"/favicon.ico", false, http.StatusOK, "ico", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL.Path = tt.path
+		if tt.gzip {
+			r.Header.Set("Accept-Encoding", "br, gzip")
+		}
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("GET %s: status %d, want %d", tt.path, w.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		if w.Body.String() != tt.body {
+			t.Errorf("GET %s: got %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+		if encoding := w.Header().Get("Content-Encoding"); encoding != tt.encoding {
+			t.Errorf("GET %s: Content-Encoding %q, want %q", tt.path, encoding, tt.encoding)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/css/main.css", nil))
+	if w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+	}
+
+	engine.NoRoute(func(ctx *Context) {
+		ctx.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+	})
+	for _, p := range []string{"/static/none.css", "/private/img/", "/none"} {
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), `"error":"not found"`) {
+			t.Errorf("GET %s with NoRoute: %d %q", p, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestEngineWrap(t *testing.T) {
+	engine := New()
+	engine.Use(WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Std-Middleware", "1")
+			next.ServeHTTP(w, r)
+		})
+	}))
+	g := engine.Group("std")
+	g.Get("/handler", WrapH(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "handler ", r.URL.Path)
+	})))
+	g.Get("/func", WrapF(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "func")
+	}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "mux ", r.URL.Path)
+	})
+	g.Mount("/legacy", mux)
+
+	sub := New()
+	sub.Get("/users/:id", func(ctx *Context) {
+		fmt.Fprintf(ctx.W, "sub %s", ctx.Param("id"))
+	})
+	g.Mount("/sub/", sub)
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/std/handler", http.StatusOK, "handler /std/handler"},
+		{"/std/func", http.StatusOK, "func"},
+		{"/std/legacy", http.StatusOK, "mux /"},
+		{"/std/legacy/a/b", http.StatusOK, "mux /a/b"},
+		{"/std/sub/users/3", http.StatusOK, "sub 3"},
+		{"/std/sub/none", http.StatusNotFound, "/std/sub/none  not found \n"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("GET %s: %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		if w.Header().Get("X-Std-Middleware") != "1" {
+			t.Errorf("GET %s: standard middleware not applied", tt.path)
+		}
+	}
+}
+
+func TestContextAbort(t *testing.T) {
+	engine := New()
+	var calls []string
+	engine.UseHandlers(func(ctx *Context) {
+		calls = append(calls, "outer")
+		ctx.Next()
+		calls = append(calls, fmt.Sprintf("aborted=%v", ctx.IsAborted()))
+	})
+	g := engine.Group("admin")
+	g.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			calls = append(calls, "wrap")
+			if ctx.R.Header.Get("X-Skip") == "" {
+				next(ctx)
+			}
+		}
+	})
+	g.UseHandlers(func(ctx *Context) {
+		if ctx.R.Header.Get("Authorization") == "" {
+			_ = ctx.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"msg": "unauthorized"})
+			return
+		}
+		ctx.Next()
+	})
+	g.Get("/info", func(ctx *Context) {
+		calls = append(calls, "handler")
+		fmt.Fprint(ctx.W, "info")
+	})
+	// 被 Recovery 恢复的 panic 也会中止后面的处理函数
+	p := engine.Group("panic")
+	p.Use(Recovery)
+	p.Handle(http.MethodGet, "", func(ctx *Context) {
+		calls = append(calls, "route")
+		panic(errors.New("boom"))
+	}, func(ctx *Context) {
+		calls = append(calls, "handler")
+	})
+
+	tests := []struct {
+		path   string
+		header string
+		code   int
+		calls  string
+	}{
+		{"/admin/info", "Authorization", http.StatusOK, "[outer wrap handler aborted=false]"},
+		{"/admin/info", "", http.StatusUnauthorized, "[outer wrap aborted=true]"},
+		{"/admin/info", "X-Skip", http.StatusOK, "[outer wrap aborted=true]"},
+		{"/panic", "", http.StatusInternalServerError, "[outer route aborted=true]"},
+	}
+	for _, tt := range tests {
+		calls = nil
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, "1")
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != tt.code || fmt.Sprint(calls) != tt.calls {
+			t.Errorf("GET %s %s: %d %v, want %d %s", tt.path, tt.header, w.Code, calls, tt.code, tt.calls)
+		}
+	}
+}
+
+func TestEngineUse(t *testing.T) {
+	engine := New()
+	var calls []string
+	record := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) {
+				calls = append(calls, name)
+				next(ctx)
+			}
+		}
+	}
+	// 全局中间件在路由注册之后 Use 也对所有路由生效
+	engine.Get("/healthz", func(ctx *Context) {})
+	g := engine.Group("user")
+	g.Use(record("group"))
+	g.Get("/info", func(ctx *Context) {})
+	// 和以前层层包装一样，后添加的在外层先执行
+	engine.Use(record("first"))
+	engine.Use(record("second"))
+	engine.Host("api.example.com").Get("/info", func(ctx *Context) {})
+
+	tests := []struct {
+		method string
+		host   string
+		path   string
+		calls  string
+	}{
+		{http.MethodGet, "", "/healthz", "[second first]"},
+		{http.MethodGet, "", "/user/info", "[second first group]"},
+		{http.MethodGet, "api.example.com", "/info", "[second first]"},
+		{http.MethodGet, "", "/none", "[second first]"},
+		{http.MethodPost, "", "/user/info", "[second first]"},
+	}
+	for _, tt := range tests {
+		calls = nil
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+		if fmt.Sprint(calls) != tt.calls {
+			t.Errorf("%s %s%s: middlewares called %v, want %s", tt.method, tt.host, tt.path, calls, tt.calls)
+		}
+	}
+}
+
+func TestContextKeys(t *testing.T) {
+	type user struct{ name string }
+	engine := New()
+	g := engine.Group("user")
+	g.UseHandlers(func(ctx *Context) {
+		if name := ctx.R.Header.Get("X-User"); name != "" {
+			ctx.Set("user", &user{name: name})
+			ctx.Set("login", time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC))
+			ctx.Set("roles", []string{"admin"})
+		}
+		ctx.Next()
+	})
+	g.Get("/me", func(ctx *Context) {
+		u, ok := GetAs[*user](ctx, "user")
+		if !ok {
+			fmt.Fprint(ctx.W, "anonymous")
+			return
+		}
+		fmt.Fprint(ctx.W, u.name, " ", ctx.GetTime("login").Year(), " ", ctx.GetStringSlice("roles"), " ", ctx.GetString("user"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/user/me", nil)
+	req.Header.Set("X-User", "mszlu")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Body.String() != "mszlu 2023 [admin] " {
+		t.Errorf("GET /user/me: got %q", w.Body.String())
+	}
+	// Context 放回 pool 时清空了数据
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/me", nil))
+	if w.Body.String() != "anonymous" {
+		t.Errorf("GET /user/me without user: got %q", w.Body.String())
+	}
+
+	ctx := &Context{}
+	if _, exists := ctx.Get("none"); exists {
+		t.Error("Get on empty context: exists")
+	}
+	ctx.Set("count", 3)
+	if ctx.GetInt("count") != 3 || ctx.GetInt64("count") != 0 || ctx.MustGet("count") != 3 {
+		t.Errorf("count: int %d int64 %d", ctx.GetInt("count"), ctx.GetInt64("count"))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustGet missing key: no panic")
+		}
+	}()
+	ctx.MustGet("none")
+}
+
+func TestContextContext(t *testing.T) {
+	type traceKey struct{}
+	engine := New()
+	var errs []error
+	engine.Get("/query", func(ctx *Context) {
+		ctx.Set("user", "mszlu")
+		// 模拟调用数据库、http 客户端等接收 context.Context 的下游
+		query := func(c context.Context) {
+			select {
+			case <-c.Done():
+				errs = append(errs, c.Err())
+			default:
+				errs = append(errs, nil)
+			}
+			fmt.Fprint(ctx.W, c.Value(traceKey{}), " ", c.Value("user"))
+		}
+		query(ctx)
+	})
+
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), traceKey{}, "trace-1"))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/query", nil).WithContext(parent))
+	if w.Body.String() != "trace-1 mszlu" {
+		t.Errorf("GET /query: got %q", w.Body.String())
+	}
+	// 客户端断开连接后请求 context 被取消
+	cancel()
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/query", nil).WithContext(parent))
+	if fmt.Sprint(errs) != fmt.Sprint([]error{nil, context.Canceled}) {
+		t.Errorf("errs = %v, want [<nil> context canceled]", errs)
+	}
+}
+
+func TestContextReset(t *testing.T) {
+	engine := New()
+	engine.Post("/form", func(ctx *Context) {
+		name, _ := ctx.GetPostForm("name")
+		fmt.Fprintf(ctx.W, "%q %v", name, ctx.IsValidate)
+		ctx.IsValidate = true
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader("name=mszlu"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Body.String() != `"mszlu" false` {
+		t.Errorf("POST /form: got %q", w.Body.String())
+	}
+	// 复用的 Context 不能读到上一个请求的表单和设置
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/form", nil))
+	if w.Body.String() != `"" false` {
+		t.Errorf("POST /form without body: got %q", w.Body.String())
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	engine := New()
+	done := make(chan *Context, 2)
+	engine.Get("/async/:id", func(ctx *Context) {
+		ctx.Set("user", "mszlu")
+		cp := ctx.Copy()
+		go func() {
+			done <- cp
+		}()
+	})
+
+	parent, cancel := context.WithCancel(context.Background())
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/async/1?page=2", nil).WithContext(parent))
+	cancel()
+	cp := <-done
+	// 原来的 Context 已经被下一个请求复用
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/async/2?page=3", nil))
+	if cp.Param("id") != "1" || cp.GetQuery("page") != "2" || cp.GetString("user") != "mszlu" {
+		t.Errorf("copy: id %q page %q user %q", cp.Param("id"), cp.GetQuery("page"), cp.GetString("user"))
+	}
+	if cp.Err() != nil {
+		t.Errorf("copy: Err() = %v, want nil", cp.Err())
+	}
+	if _, err := cp.W.Write([]byte("late")); err != ErrCopiedContext {
+		t.Errorf("copy: Write error %v, want %v", err, ErrCopiedContext)
+	}
+	if !cp.IsAborted() {
+		t.Error("copy: not aborted")
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	logFile, err := os.CreateTemp(t.TempDir(), "msgo.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logFile.Close()
+	engine := New()
+	engine.Logger = msLog.Default()
+	engine.Logger.Outs = []*msLog.LoggerWriter{{Level: -1, Out: logFile}}
+	var status, size []int
+	engine.UseHandlers(func(ctx *Context) {
+		ctx.Next()
+		status = append(status, ctx.W.Status())
+		size = append(size, ctx.W.Size())
+	})
+	engine.Get("/fprint", func(ctx *Context) {
+		fmt.Fprint(ctx.W, "hello")
+	})
+	engine.Get("/created", func(ctx *Context) {
+		ctx.W.WriteHeader(http.StatusCreated)
+		fmt.Fprint(ctx.W, "created")
+		ctx.W.WriteHeader(http.StatusInternalServerError)
+	})
+	engine.Get("/nocontent", func(ctx *Context) {
+		ctx.W.WriteHeader(http.StatusNoContent)
+	})
+	engine.Get("/flush", func(ctx *Context) {
+		ctx.W.Flush()
+		if _, _, err := ctx.W.Hijack(); err == nil {
+			t.Error("Hijack on httptest.ResponseRecorder: no error")
+		}
+	})
+	g := engine.Group("std")
+	g.Use(WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(struct{ http.ResponseWriter }{w}, r)
+		})
+	}))
+	g.Get("/accepted", func(ctx *Context) {
+		ctx.W.WriteHeader(http.StatusAccepted)
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/fprint", http.StatusOK, "hello"},
+		{"/created", http.StatusCreated, "created"},
+		{"/nocontent", http.StatusNoContent, ""},
+		{"/flush", http.StatusOK, ""},
+		{"/std/accepted", http.StatusAccepted, ""},
+		{"/none", http.StatusNotFound, "/none  not found \n"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("GET %s: %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		if tt.path == "/flush" && !w.Flushed {
+			t.Error("GET /flush: not flushed")
+		}
+	}
+	if fmt.Sprint(status) != "[200 201 204 200 202 404]" || fmt.Sprint(size) != "[5 7 -1 0 -1 18]" {
+		t.Errorf("middleware saw status %v size %v", status, size)
+	}
+	logs, _ := os.ReadFile(logFile.Name())
+	if !strings.Contains(string(logs), "superfluous WriteHeader") {
+		t.Errorf("superfluous WriteHeader not logged: %q", logs)
+	}
+}
+
+func TestEngineRunListener(t *testing.T) {
+	// 同一个进程中运行多个 Engine，不会注册到全局的 http.DefaultServeMux 上互相影响
+	names := []string{"order", "user"}
+	addrs := make([]string, len(names))
+	for i, name := range names {
+		name := name
+		engine := New()
+		engine.ReadHeaderTimeout = time.Second
+		engine.Get("/name", func(ctx *Context) {
+			fmt.Fprint(ctx.W, name)
+		})
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		addrs[i] = ln.Addr().String()
+		go engine.RunListener(ln)
+	}
+	for i, addr := range addrs {
+		resp, err := http.Get("http://" + addr + "/name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != names[i] {
+			t.Errorf("GET %s/name: got %q, want %q", addr, body, names[i])
+		}
+	}
+
+	if err := New().Run("127.0.0.1:-1"); err == nil {
+		t.Error("Run on invalid address: no error")
+	}
+}
+
+func TestEngineGracefulShutdown(t *testing.T) {
+	engine := New()
+	started := make(chan struct{})
+	engine.Get("/ping", func(ctx *Context) {})
+	engine.Get("/slow", func(ctx *Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(ctx.W, "done")
+	})
+	var hooks []string
+	engine.OnShutdown(func(ctx context.Context) error {
+		hooks = append(hooks, "pool")
+		return nil
+	}, func(ctx context.Context) error {
+		hooks = append(hooks, "logger")
+		return errors.New("close failed")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "http://" + ln.Addr().String()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- engine.serveWithGracefulShutdown(ln)
+	}()
+	waitServing(t, addr+"/ping")
+
+	bodyc := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(addr + "/slow")
+		if err != nil {
+			bodyc <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		bodyc <- string(body)
+	}()
+	<-started
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	// 正在处理的请求完成后才退出
+	if body := <-bodyc; body != "done" {
+		t.Errorf("GET /slow: got %q, want done", body)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("serveWithGracefulShutdown: %v", err)
+	}
+	if fmt.Sprint(hooks) != "[logger pool]" {
+		t.Errorf("hooks called %v, want [logger pool]", hooks)
+	}
+	if _, err := http.Get(addr + "/ping"); err == nil {
+		t.Error("GET /ping after shutdown: no error")
+	}
+}
+
+func TestEngineGracefulShutdownH2C(t *testing.T) {
+	engine := New()
+	engine.UseH2C = true
+	started := make(chan struct{})
+	engine.Get("/slow", func(ctx *Context) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		fmt.Fprint(ctx.W, "done")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go engine.RunListener(ln)
+
+	// h2c 接管的连接上正在处理的请求也要等待完成
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	bodyc := make(chan string, 1)
+	go func() {
+		resp, err := client.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			bodyc <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		bodyc <- string(body)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := engine.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Shutdown returned after %v without waiting for the h2c request", elapsed)
+	}
+	if body := <-bodyc; body != "done" {
+		t.Errorf("GET /slow over h2c: got %q, want done", body)
+	}
+}
+
+func TestEngineShutdownTimeout(t *testing.T) {
+	engine := New()
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	engine.Get("/wait", func(ctx *Context) {
+		close(started)
+		<-ctx.Done()
+		canceled <- ctx.Err()
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 等待请求超时之后，OnShutdown 注册的函数拿到的 context 还没有结束
+	hookErr := make(chan error, 1)
+	engine.OnShutdown(func(ctx context.Context) error {
+		hookErr <- ctx.Err()
+		return nil
+	})
+	go engine.RunListener(ln)
+	go http.Get("http://" + ln.Addr().String() + "/wait")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := engine.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown: %v, want %v", err, context.DeadlineExceeded)
+	}
+	// 超时后取消请求的 context
+	if err := <-canceled; err != context.Canceled {
+		t.Errorf("request context: %v, want %v", err, context.Canceled)
+	}
+	if err := <-hookErr; err != nil {
+		t.Errorf("shutdown hook context: %v, want nil", err)
+	}
+}
+
+func TestEngineShutdownBeforeRun(t *testing.T) {
+	engine := New()
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- engine.RunListener(ln)
+	}()
+	select {
+	case err := <-errc:
+		if err != http.ErrServerClosed {
+			t.Errorf("RunListener after Shutdown: %v, want %v", err, http.ErrServerClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunListener after Shutdown is still serving")
+	}
+	// listener 已经关闭
+	if _, err := ln.Accept(); err == nil {
+		t.Error("listener is not closed")
+	}
+}
+
+// waitServing 等待服务开始处理请求
+func waitServing(t *testing.T, url string) {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s is not serving", url)
+}
+
+func TestEngineLifecycleHooks(t *testing.T) {
+	engine := New()
+	var calls []string
+	engine.OnStart(func() error {
+		calls = append(calls, "start")
+		return nil
+	})
+	ready := make(chan net.Addr, 1)
+	engine.OnReady(func(addr net.Addr) error {
+		calls = append(calls, "ready")
+		ready <- addr
+		return nil
+	})
+	engine.OnShutdown(func(ctx context.Context) error {
+		calls = append(calls, "shutdown")
+		return nil
+	})
+	engine.OnRequest(func(ctx *Context) {
+		calls = append(calls, "request "+ctx.R.URL.Path)
+	})
+	engine.OnResponse(func(ctx *Context) {
+		calls = append(calls, fmt.Sprintf("response %d", ctx.W.Status()))
+	})
+	engine.Get("/ping", func(ctx *Context) {
+		calls = append(calls, "handler")
+	})
+
+	// 监听 Unix socket，通过 OnReady 得到实际监听的地址
+	sock := filepath.Join(t.TempDir(), "msgo.sock")
+	errc := make(chan error, 1)
+	go func() {
+		errc <- engine.RunUnix(sock)
+	}()
+	addr := <-ready
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, addr.Network(), addr.String())
+		},
+	}}
+	for _, path := range []string{"/ping", "/none"} {
+		resp, err := client.Get("http://unix" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("RunUnix: %v", err)
+	}
+	want := "[start ready request /ping handler response 200 request /none response 404 shutdown]"
+	if fmt.Sprint(calls) != want {
+		t.Errorf("hooks called %v, want %s", calls, want)
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket file not removed: %v", err)
+	}
+
+	// OnStart、OnReady 出错时不再启动
+	startErr := errors.New("cache warm up failed")
+	engine = New()
+	engine.OnStart(func() error {
+		return startErr
+	})
+	if err := engine.Run("127.0.0.1:0"); err != startErr {
+		t.Errorf("Run with failed start hook: %v, want %v", err, startErr)
+	}
+	readyErr := errors.New("register failed")
+	engine = New()
+	engine.OnReady(func(addr net.Addr) error {
+		return readyErr
+	})
+	if err := engine.Run("127.0.0.1:0"); err != readyErr {
+		t.Errorf("Run with failed ready hook: %v, want %v", err, readyErr)
+	}
+}
+
+// runTestServer 在 goroutine 中启动服务，通过 OnReady 拿到监听 127.0.0.1:0 分配的地址
+func runTestServer(t *testing.T, engine *Engine, run func() error) string {
+	t.Helper()
+	ready := make(chan string, 1)
+	engine.OnReady(func(addr net.Addr) error {
+		ready <- addr.String()
+		return nil
+	})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- run()
+	}()
+	t.Cleanup(func() {
+		engine.Shutdown(context.Background())
+	})
+	select {
+	case addr := <-ready:
+		return addr
+	case err := <-errc:
+		t.Fatalf("run: %v", err)
+	}
+	return ""
+}
+
+func TestEngineH2C(t *testing.T) {
+	engine := New()
+	engine.UseH2C = true
+	engine.Get("/proto", func(ctx *Context) {
+		fmt.Fprint(ctx.W, ctx.R.Proto)
+	})
+	addr := runTestServer(t, engine, func() error {
+		return engine.Run("127.0.0.1:0")
+	})
+
+	// prior knowledge：客户端直接使用 HTTP/2
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Get("http://" + addr + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	// 空闲的 h2c 连接会让 Shutdown 等到连接关闭
+	client.CloseIdleConnections()
+	if string(body) != "HTTP/2.0" {
+		t.Errorf("h2c prior knowledge: got %q, want HTTP/2.0", body)
+	}
+
+	// Upgrade：HTTP/1.1 请求升级成 h2c
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /proto HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAAP__\r\n\r\n", addr)
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || !strings.HasPrefix(status, "HTTP/1.1 101") {
+		t.Errorf("h2c upgrade: status %q, err %v", status, err)
+	}
+
+	// 没有开启 UseH2C 时只支持 HTTP/1.1
+	plain := New()
+	plain.Get("/proto", func(ctx *Context) {})
+	addr = runTestServer(t, plain, func() error {
+		return plain.Run("127.0.0.1:0")
+	})
+	if _, err := client.Get("http://" + addr + "/proto"); err == nil {
+		t.Error("h2c without UseH2C: no error")
+	}
+}
+
+func TestEngineRunTLSHTTP2(t *testing.T) {
+	certFile, keyFile, pool := writeTestCert(t)
+	engine := New()
+	engine.Get("/proto", func(ctx *Context) {
+		fmt.Fprint(ctx.W, ctx.R.Proto)
+	})
+	addr := runTestServer(t, engine, func() error {
+		return engine.RunTLS("127.0.0.1:0", certFile, keyFile)
+	})
+
+	// 客户端通过 ALPN 协商是否使用 HTTP/2
+	tests := []struct {
+		http2 bool
+		proto string
+	}{
+		{true, "HTTP/2.0"},
+		{false, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		transport := &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			ForceAttemptHTTP2: tt.http2,
+		}
+		resp, err := (&http.Client{Transport: transport}).Get("https://" + addr + "/proto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		transport.CloseIdleConnections()
+		if string(body) != tt.proto {
+			t.Errorf("http2=%v: got %q, want %s", tt.http2, body, tt.proto)
+		}
+	}
+}
+
+func TestEngineRunTLSBadCert(t *testing.T) {
+	engine := New()
+	ready := false
+	engine.OnReady(func(addr net.Addr) error {
+		ready = true
+		return nil
+	})
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	if err := engine.RunTLS("127.0.0.1:0", missing, missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("RunTLS with missing cert: %v, want %v", err, os.ErrNotExist)
+	}
+	// 证书加载失败时不执行 OnReady 注册的函数
+	if ready {
+		t.Error("ready hook ran before the certificate was loaded")
+	}
+}
+
+// writeTestCert 生成 127.0.0.1 的自签名证书，返回证书和私钥文件以及信任该证书的 CertPool
+func writeTestCert(t *testing.T) (string, string, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"msgo"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
@@ -0,0 +1,142 @@
+package msgo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	msLog "github.com/H-kang-better/msgo/log"
+	"io"
+	"net"
+	"net/http"
+)
+
+// noWritten 响应头还没有发送时 size 的值
+const noWritten = -1
+
+// ResponseWriter 包装 http.ResponseWriter，记录状态码、写入的字节数以及响应头是否已经发送
+// 底层的 ResponseWriter 不支持 Flusher、Hijacker、CloseNotifier、Pusher 时对应的方法什么也不做或者返回错误
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.CloseNotifier
+	http.Pusher
+	// Status 响应的状态码，没有调用 WriteHeader 时为 200
+	Status() int
+	// Size 写入响应体的字节数，响应头还没有发送时为 -1
+	Size() int
+	// Written 响应头是否已经发送
+	Written() bool
+	// WriteHeaderNow 立即发送响应头，WriteHeader 只记录状态码，第一次 Write 时才发送
+	WriteHeaderNow()
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+	noBody bool // HEAD 请求复用 GET 的处理函数时丢弃响应体
+	logger *msLog.Logger
+}
+
+var _ ResponseWriter = (*responseWriter)(nil)
+
+func (w *responseWriter) reset(writer http.ResponseWriter, logger *msLog.Logger) {
+	w.ResponseWriter = writer
+	w.status = http.StatusOK
+	w.size = noWritten
+	w.noBody = false
+	w.logger = logger
+}
+
+// Unwrap 返回底层的 http.ResponseWriter，供 http.ResponseController 使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && w.status != code {
+		if w.Written() {
+			if w.logger != nil {
+				w.logger.Info(fmt.Sprintf("[WARNING] msgo: superfluous WriteHeader call, headers were already written with status %d, new status %d ignored", w.status, code))
+			}
+			return
+		}
+		w.status = code
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	if w.noBody {
+		w.size += len(b)
+		return len(b), nil
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	if w.noBody {
+		w.size += len(s)
+		return len(s), nil
+	}
+	n, err := io.WriteString(w.ResponseWriter, s)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("msgo: ResponseWriter does not implement http.Hijacker")
+	}
+	// 连接交给调用方之后不能再写响应头
+	if w.size < 0 {
+		w.size = 0
+	}
+	return h.Hijack()
+}
+
+// CloseNotify 底层不支持时返回 nil，永远不会收到通知
+func (w *responseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
@@ -0,0 +1,61 @@
+package msgo
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+)
+
+// RouteInfo 注册的路由信息
+type RouteInfo struct {
+	Host        string `json:"host,omitempty"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Name        string `json:"name,omitempty"`
+	Handler     string `json:"handler"`
+	Middlewares int    `json:"middlewares"`
+}
+
+// Routes 按注册顺序返回所有注册的路由
+func (e *Engine) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(e.routes))
+	for _, rt := range e.routes {
+		routes = append(routes, RouteInfo{
+			Host:        rt.group.host,
+			Method:      rt.method,
+			Path:        rt.path,
+			Name:        rt.name,
+			Handler:     nameOfFunction(rt.handler),
+			Middlewares: rt.middlewareCount(),
+		})
+	}
+	return routes
+}
+
+// RoutesHandler 以 json 格式返回路由表，engine.Get("/debug/routes", engine.RoutesHandler())
+func (e *Engine) RoutesHandler() HandlerFunc {
+	return func(ctx *Context) {
+		ctx.JSON(http.StatusOK, e.Routes())
+	}
+}
+
+// printRoutes 开启 PrintRoutes 时，启动的时候打印路由表，只在 Logger 的级别为 Debug 时输出
+func (e *Engine) printRoutes() {
+	if !e.PrintRoutes {
+		return
+	}
+	for _, rt := range e.Routes() {
+		e.Logger.Debug(fmt.Sprintf("%-7s %-30s --> %s (%d middlewares)", rt.Method, rt.Host+rt.Path, rt.Handler, rt.Middlewares))
+	}
+}
+
+// middlewareCount 路由经过的中间件个数
+func (rt *Route) middlewareCount() int {
+	return len(rt.handlers) - 1
+}
+
+// nameOfFunction 获取函数的名称，如 main.main.func1
+func nameOfFunction(f any) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
@@ -0,0 +1,417 @@
+package msgo
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultAddr Run 没有传地址时监听的地址
+const defaultAddr = ":8111"
+
+// defaultShutdownTimeout 优雅关闭时默认等待请求处理完成的时间
+const defaultShutdownTimeout = 10 * time.Second
+
+// serverState Run 系列方法启动的服务，用于 Shutdown
+type serverState struct {
+	mu            sync.Mutex
+	servers       map[*http.Server]struct{}
+	baseCtx       context.Context // 所有请求 context 的父 context，关闭超时后取消
+	cancelBase    context.CancelFunc
+	shuttingDown  bool                  // 调用 Shutdown 之后不再启动新的服务
+	h2cConns      map[net.Conn]struct{} // 被 h2c 接管的连接，http.Server.Shutdown 不会等待和关闭它们
+	h2cIdle       chan struct{}         // h2cConns 清空时关闭
+	startHooks    []func() error
+	readyHooks    []func(addr net.Addr) error
+	shutdownHooks []func(ctx context.Context) error
+}
+
+// Run 监听 TCP 地址并处理请求，默认监听 :8111，engine.Run(":8080")
+// 出错时返回错误，不会退出进程
+func (e *Engine) Run(addr ...string) error {
+	address := defaultAddr
+	if len(addr) > 0 && addr[0] != "" {
+		address = addr[0]
+	}
+	ln, err := e.listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return e.serve(e.newServer(), ln, false, "", "")
+}
+
+// RunWithGracefulShutdown 和 Run 一样监听地址并处理请求，收到 SIGINT、SIGTERM 后停止接收新的连接，
+// 最多等待 ShutdownTimeout 让正在处理的请求完成，然后倒序执行 OnShutdown 注册的函数
+func (e *Engine) RunWithGracefulShutdown(addr ...string) error {
+	address := defaultAddr
+	if len(addr) > 0 && addr[0] != "" {
+		address = addr[0]
+	}
+	ln, err := e.listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return e.serveWithGracefulShutdown(ln)
+}
+
+func (e *Engine) serveWithGracefulShutdown(ln net.Listener) error {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	// 启动协程之前先记录服务，协程还没有开始处理请求时收到信号也能关闭它
+	srv := e.newServer()
+	if err := e.trackServer(srv); err != nil {
+		ln.Close()
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- e.serve(srv, ln, false, "", "")
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-quit:
+		e.Logger.Info(fmt.Sprintf("msgo: received %s, shutting down", sig))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.shutdownTimeout())
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		return err
+	}
+	return <-errc
+}
+
+// shutdownTimeout 优雅关闭时等待请求处理完成的时间，也是 OnShutdown 注册的函数的超时时间
+func (e *Engine) shutdownTimeout() time.Duration {
+	if e.ShutdownTimeout <= 0 {
+		return defaultShutdownTimeout
+	}
+	return e.ShutdownTimeout
+}
+
+// Shutdown 关闭 Run 系列方法启动的服务：停止接收新的连接，等待正在处理的请求完成，
+// ctx 结束时还没有完成的请求，取消它们的 context 并强制关闭连接，最后倒序执行 OnShutdown 注册的函数。
+// Shutdown 之后再调用 Run 系列方法会直接返回 http.ErrServerClosed
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.shuttingDown = true
+	servers := make([]*http.Server, 0, len(e.servers))
+	for srv := range e.servers {
+		servers = append(servers, srv)
+	}
+	cancelBase := e.cancelBase
+	e.baseCtx, e.cancelBase = nil, nil
+	hooks := e.shutdownHooks
+	e.shutdownHooks = nil
+	e.mu.Unlock()
+
+	var err error
+	for _, srv := range servers {
+		if shutdownErr := srv.Shutdown(ctx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	}
+	if err == nil {
+		err = e.waitH2C(ctx)
+	}
+	// 超时的时候还在处理的请求通过 context 得知服务关闭，尽快退出
+	if cancelBase != nil {
+		cancelBase()
+	}
+	if err != nil {
+		e.Logger.Error(fmt.Sprintf("msgo: shutdown: %v, closing active connections", err))
+		for _, srv := range servers {
+			srv.Close()
+		}
+		e.closeH2C()
+	}
+	// 等待请求超时的时候 ctx 已经结束了，OnShutdown 注册的函数使用新的 context
+	hookCtx, cancel := context.WithTimeout(context.Background(), e.shutdownTimeout())
+	defer cancel()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hookErr := hooks[i](hookCtx); hookErr != nil {
+			e.Logger.Error(fmt.Sprintf("msgo: shutdown hook: %v", hookErr))
+		}
+	}
+	return err
+}
+
+// OnStart 注册启动时执行的函数，如预热缓存、打开日志文件，在监听地址之前按注册顺序执行，出错时不再启动
+func (e *Engine) OnStart(hooks ...func() error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.startHooks = append(e.startHooks, hooks...)
+}
+
+// OnReady 注册监听地址之后执行的函数，addr 是实际监听的地址，监听 :0 时可以拿到分配的端口，出错时不再启动
+func (e *Engine) OnReady(hooks ...func(addr net.Addr) error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.readyHooks = append(e.readyHooks, hooks...)
+}
+
+// OnShutdown 注册 Shutdown 时执行的函数，如释放协程池、关闭日志文件，按注册顺序倒序执行，出错时记录日志。
+// 函数收到的 ctx 不是传给 Shutdown 的 ctx，而是在请求处理完成之后新建的，超时时间为 ShutdownTimeout
+func (e *Engine) OnShutdown(hooks ...func(ctx context.Context) error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdownHooks = append(e.shutdownHooks, hooks...)
+}
+
+// RunTLS 监听 TCP 地址并处理 HTTPS 请求，certFile 和 keyFile 是证书和私钥文件
+func (e *Engine) RunTLS(addr string, certFile string, keyFile string) error {
+	ln, err := e.listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return e.serve(e.newServer(), ln, true, certFile, keyFile)
+}
+
+// RunUnix 监听 Unix socket 文件并处理请求，退出时删除 socket 文件
+func (e *Engine) RunUnix(file string) error {
+	ln, err := e.listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	return e.serve(e.newServer(), ln, false, "", "")
+}
+
+// RunListener 在已有的 net.Listener 上处理请求，如测试中监听 127.0.0.1:0
+func (e *Engine) RunListener(ln net.Listener) error {
+	if err := e.runStartHooks(); err != nil {
+		ln.Close()
+		return err
+	}
+	return e.serve(e.newServer(), ln, false, "", "")
+}
+
+// RunServer 使用自定义的 http.Server 处理请求，Handler 为空时使用 engine，
+// 没有设置的超时时间使用 Engine 上的配置，配置了 TLSConfig 的证书时处理 HTTPS 请求
+func (e *Engine) RunServer(srv *http.Server) error {
+	if srv.Handler == nil {
+		srv.Handler = e
+	}
+	if srv.ReadTimeout == 0 {
+		srv.ReadTimeout = e.ReadTimeout
+	}
+	if srv.WriteTimeout == 0 {
+		srv.WriteTimeout = e.WriteTimeout
+	}
+	if srv.IdleTimeout == 0 {
+		srv.IdleTimeout = e.IdleTimeout
+	}
+	if srv.ReadHeaderTimeout == 0 {
+		srv.ReadHeaderTimeout = e.ReadHeaderTimeout
+	}
+	addr := srv.Addr
+	if addr == "" {
+		addr = defaultAddr
+	}
+	ln, err := e.listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	useTLS := srv.TLSConfig != nil && (len(srv.TLSConfig.Certificates) > 0 || srv.TLSConfig.GetCertificate != nil)
+	return e.serve(srv, ln, useTLS, "", "")
+}
+
+// listen 执行 OnStart 注册的函数之后监听地址
+func (e *Engine) listen(network string, address string) (net.Listener, error) {
+	if err := e.runStartHooks(); err != nil {
+		return nil, err
+	}
+	return net.Listen(network, address)
+}
+
+func (e *Engine) runStartHooks() error {
+	e.mu.Lock()
+	hooks := e.startHooks
+	e.mu.Unlock()
+	for _, hook := range hooks {
+		if err := hook(); err != nil {
+			e.Logger.Error(fmt.Sprintf("msgo: start hook: %v", err))
+			return err
+		}
+	}
+	return nil
+}
+
+// newServer 创建 Run 系列方法使用的 http.Server，不使用全局的 http.DefaultServeMux
+func (e *Engine) newServer() *http.Server {
+	return &http.Server{
+		Handler:           e,
+		ReadTimeout:       e.ReadTimeout,
+		WriteTimeout:      e.WriteTimeout,
+		IdleTimeout:       e.IdleTimeout,
+		ReadHeaderTimeout: e.ReadHeaderTimeout,
+	}
+}
+
+// trackServer 记录服务，让 Shutdown 可以关闭它，已经调用过 Shutdown 时返回 http.ErrServerClosed
+func (e *Engine) trackServer(srv *http.Server) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.servers[srv]; ok {
+		return nil
+	}
+	if e.shuttingDown {
+		return http.ErrServerClosed
+	}
+	if e.servers == nil {
+		e.servers = make(map[*http.Server]struct{})
+	}
+	e.servers[srv] = struct{}{}
+	if e.baseCtx == nil {
+		e.baseCtx, e.cancelBase = context.WithCancel(context.Background())
+	}
+	if srv.BaseContext == nil {
+		baseCtx := e.baseCtx
+		srv.BaseContext = func(net.Listener) context.Context {
+			return baseCtx
+		}
+	}
+	return nil
+}
+
+type h2cConnKey struct{}
+
+// trackH2C 记录 h2c 接管的连接，h2c 在 handler 中接管连接并处理到连接关闭为止，
+// http.Server.Shutdown 把这些连接当作已经关闭，由 Shutdown 等待它们的请求处理完成
+func (e *Engine) trackH2C(srv *http.Server, h http.Handler) http.Handler {
+	connContext := srv.ConnContext
+	srv.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if connContext != nil {
+			ctx = connContext(ctx, c)
+		}
+		return context.WithValue(ctx, h2cConnKey{}, c)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, ok := r.Context().Value(h2cConnKey{}).(net.Conn)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		e.mu.Lock()
+		if e.h2cConns == nil {
+			e.h2cConns = make(map[net.Conn]struct{})
+		}
+		e.h2cConns[conn] = struct{}{}
+		e.mu.Unlock()
+		defer func() {
+			e.mu.Lock()
+			delete(e.h2cConns, conn)
+			if len(e.h2cConns) == 0 && e.h2cIdle != nil {
+				close(e.h2cIdle)
+				e.h2cIdle = nil
+			}
+			e.mu.Unlock()
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// waitH2C 等待 h2c 连接关闭，Shutdown 时这些连接收到 GOAWAY，正在处理的请求完成后关闭，
+// 客户端不主动关闭的空闲连接由 http2 在 GOAWAY 之后 1 秒关闭
+func (e *Engine) waitH2C(ctx context.Context) error {
+	e.mu.Lock()
+	if len(e.h2cConns) == 0 {
+		e.mu.Unlock()
+		return nil
+	}
+	if e.h2cIdle == nil {
+		e.h2cIdle = make(chan struct{})
+	}
+	idle := e.h2cIdle
+	e.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeH2C 强制关闭 h2c 连接，http.Server.Close 不会关闭被接管的连接
+func (e *Engine) closeH2C() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for conn := range e.h2cConns {
+		conn.Close()
+	}
+}
+
+// serve 所有的 Run 方法最终都在 listener 上处理请求，服务关闭时返回 nil，调用过 Shutdown 时返回 http.ErrServerClosed
+// 使用 TLS 时通过 ALPN 协商 HTTP/2，开启 UseH2C 时明文连接也支持 HTTP/2
+func (e *Engine) serve(srv *http.Server, ln net.Listener, useTLS bool, certFile string, keyFile string) error {
+	if err := e.trackServer(srv); err != nil {
+		ln.Close()
+		return err
+	}
+	defer func() {
+		e.mu.Lock()
+		delete(e.servers, srv)
+		e.mu.Unlock()
+	}()
+	// 在执行 OnReady 注册的函数之前加载证书，证书有问题时不会通知服务已经就绪
+	if useTLS && (certFile != "" || keyFile != "") {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		config := &tls.Config{}
+		if srv.TLSConfig != nil {
+			config = srv.TLSConfig.Clone()
+		}
+		config.Certificates = []tls.Certificate{cert}
+		srv.TLSConfig = config
+		certFile, keyFile = "", ""
+	}
+	if useTLS || e.UseH2C {
+		// ConfigureServer 让 Shutdown 也能关闭 HTTP/2 连接
+		h2s := &http2.Server{IdleTimeout: srv.IdleTimeout}
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			ln.Close()
+			return err
+		}
+		if !useTLS {
+			srv.Handler = e.trackH2C(srv, h2c.NewHandler(srv.Handler, h2s))
+		}
+	}
+	e.mu.Lock()
+	readyHooks := e.readyHooks
+	e.mu.Unlock()
+
+	for _, hook := range readyHooks {
+		if err := hook(ln.Addr()); err != nil {
+			e.Logger.Error(fmt.Sprintf("msgo: ready hook: %v", err))
+			ln.Close()
+			return err
+		}
+	}
+	e.printRoutes()
+	var err error
+	if useTLS {
+		e.Logger.Info(fmt.Sprintf("Listening and serving HTTPS on %s", ln.Addr()))
+		err = srv.ServeTLS(ln, certFile, keyFile)
+	} else {
+		e.Logger.Info(fmt.Sprintf("Listening and serving HTTP on %s", ln.Addr()))
+		err = srv.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
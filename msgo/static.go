@@ -0,0 +1,201 @@
+package msgo
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StaticConfig 静态文件服务的配置
+type StaticConfig struct {
+	Browse        bool     // 访问目录且没有首页文件时是否列出目录
+	Index         []string // 访问目录时返回的首页文件，默认 index.html
+	CacheControl  string   // Cache-Control 响应头，如 public, max-age=3600
+	Precompressed bool     // 客户端支持 gzip 时优先返回同名的 .gz 文件，如 main.css.gz
+}
+
+func staticConfig(conf []StaticConfig) StaticConfig {
+	c := StaticConfig{}
+	if len(conf) > 0 {
+		c = conf[0]
+	}
+	if c.Index == nil {
+		c.Index = []string{"index.html"}
+	}
+	return c
+}
+
+// Static 将 root 目录挂载到 prefix 下，g.Static("/static", "./static")
+func (r *routerGroup) Static(prefix string, root string, conf ...StaticConfig) {
+	r.StaticFS(prefix, http.Dir(root), conf...)
+}
+
+// StaticFS 将文件系统挂载到 prefix 下，embed.FS 可以通过 EmbedFS 转换
+func (r *routerGroup) StaticFS(prefix string, fsys http.FileSystem, conf ...StaticConfig) {
+	c := staticConfig(conf)
+	pattern := strings.TrimSuffix(prefix, "/") + "/**"
+	r.Get(pattern, func(ctx *Context) {
+		serveStatic(ctx, fsys, ctx.CatchAll(), c)
+	})
+}
+
+// StaticFile 将单个文件注册到 relativePath 上，g.StaticFile("/favicon.ico", "./static/favicon.ico")
+func (r *routerGroup) StaticFile(relativePath string, file string, conf ...StaticConfig) {
+	dir, name := filepath.Split(file)
+	if dir == "" {
+		dir = "."
+	}
+	r.StaticFileFS(relativePath, name, http.Dir(dir), conf...)
+}
+
+// StaticFileFS 将文件系统中的单个文件注册到 relativePath 上
+func (r *routerGroup) StaticFileFS(relativePath string, name string, fsys http.FileSystem, conf ...StaticConfig) {
+	c := staticConfig(conf)
+	r.Get(relativePath, func(ctx *Context) {
+		serveStatic(ctx, fsys, name, c)
+	})
+}
+
+// EmbedFS 将 embed.FS 中的 dir 目录转换成 http.FileSystem
+//
+//	//go:embed static
+//	var staticFS embed.FS
+//	g.StaticFS("/static", msgo.EmbedFS(staticFS, "static"))
+func EmbedFS(fsys fs.FS, dir string) http.FileSystem {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(fmt.Sprintf("msgo: invalid embed dir '%s': %v", dir, err))
+	}
+	return http.FS(sub)
+}
+
+// serveStatic 返回文件系统中的 name 文件，name 为目录时返回首页文件或者目录列表
+func serveStatic(ctx *Context, fsys http.FileSystem, name string, conf StaticConfig) {
+	name = path.Clean("/" + name)
+	f, err := fsys.Open(name)
+	if err != nil {
+		staticNotFound(ctx)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		staticNotFound(ctx)
+		return
+	}
+	if !stat.IsDir() {
+		serveFile(ctx, fsys, name, f, stat, conf)
+		return
+	}
+	// 目录要以 / 结尾，否则页面中的相对路径会出错
+	if !strings.HasSuffix(ctx.R.URL.Path, "/") {
+		location := ctx.R.URL.Path + "/"
+		if ctx.R.URL.RawQuery != "" {
+			location += "?" + ctx.R.URL.RawQuery
+		}
+		ctx.StatusCode = http.StatusMovedPermanently
+		http.Redirect(ctx.W, ctx.R, location, http.StatusMovedPermanently)
+		return
+	}
+	for _, index := range conf.Index {
+		indexName := path.Join(name, index)
+		indexFile, err := fsys.Open(indexName)
+		if err != nil {
+			continue
+		}
+		defer indexFile.Close()
+		if indexStat, err := indexFile.Stat(); err == nil && !indexStat.IsDir() {
+			serveFile(ctx, fsys, indexName, indexFile, indexStat, conf)
+			return
+		}
+	}
+	if !conf.Browse {
+		staticNotFound(ctx)
+		return
+	}
+	dirList(ctx, f, conf)
+}
+
+// staticNotFound 文件不存在时执行 NoRoute 设置的处理函数，没有设置时返回默认的 404
+// 全局中间件已经在静态文件路由的处理函数链中执行过，这里不再重复执行
+func staticNotFound(ctx *Context) {
+	if len(ctx.engine.noRoute) == 0 {
+		defaultNoRoute(ctx)
+		return
+	}
+	for _, handler := range ctx.engine.noRoute {
+		handler(ctx)
+		if ctx.IsAborted() {
+			return
+		}
+	}
+}
+
+func serveFile(ctx *Context, fsys http.FileSystem, name string, f http.File, stat fs.FileInfo, conf StaticConfig) {
+	if conf.CacheControl != "" {
+		ctx.W.Header().Set("Cache-Control", conf.CacheControl)
+	}
+	if conf.Precompressed {
+		ctx.W.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(ctx.R) {
+			if gz, err := fsys.Open(name + ".gz"); err == nil {
+				defer gz.Close()
+				if gzStat, err := gz.Stat(); err == nil && !gzStat.IsDir() {
+					contentType := mime.TypeByExtension(path.Ext(name))
+					if contentType == "" {
+						contentType = "application/octet-stream"
+					}
+					ctx.W.Header().Set("Content-Type", contentType)
+					ctx.W.Header().Set("Content-Encoding", "gzip")
+					http.ServeContent(ctx.W, ctx.R, stat.Name(), gzStat.ModTime(), gz)
+					return
+				}
+			}
+		}
+	}
+	http.ServeContent(ctx.W, ctx.R, stat.Name(), stat.ModTime(), f)
+}
+
+// acceptsGzip 客户端是否支持 gzip，Accept-Encoding: gzip, deflate, br
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.TrimSpace(name) == "gzip" {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// dirList 列出目录下的文件
+func dirList(ctx *Context, dir http.File, conf StaticConfig) {
+	files, err := dir.Readdir(-1)
+	if err != nil {
+		ctx.Fail(http.StatusInternalServerError, "Error reading directory")
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+	if conf.CacheControl != "" {
+		ctx.W.Header().Set("Cache-Control", conf.CacheControl)
+	}
+	var sb strings.Builder
+	sb.WriteString("<pre>\n")
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() {
+			name += "/"
+		}
+		fmt.Fprintf(&sb, "<a href=\"%s\">%s</a>\n", (&url.URL{Path: name}).String(), html.EscapeString(name))
+	}
+	sb.WriteString("</pre>\n")
+	ctx.HTML(http.StatusOK, sb.String())
+}
@@ -1,83 +1,401 @@
 package msgo
 
-import "strings"
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
 
+// Param 路由中匹配到的一个路径参数
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params 路由中匹配到的所有路径参数，按照在路由中出现的顺序排列
+type Params []Param
+
+// Get 根据参数名获取参数值
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName 根据参数名获取参数值，没有返回空字符串
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
+}
+
+type nodeType uint8
+
+const (
+	staticNode   nodeType = iota // 静态路径
+	paramNode                    // :id 或 *，匹配一个路径段
+	catchAllNode                 // **，匹配剩余的所有路径
+)
+
+// treeNode 压缩前缀树（radix tree）的节点
+// 匹配优先级：静态节点 > 参数节点 > **，匹配失败时会回溯尝试优先级更低的节点
 type treeNode struct {
-	name       string
-	children   []*treeNode
-	routerName string
-	isEnd      bool
+	name       string            // 静态节点为压缩后的公共前缀，参数节点为 :id、*、**
+	nType      nodeType          // 节点类型
+	key        string            // 参数节点对应的参数名
+	constraint string            // 参数节点的约束，如 int、[0-9]+
+	check      func(string) bool // 约束的校验函数，没有约束为 nil
+	indices    string            // 静态子节点的首字节，与 children 一一对应
+	children   []*treeNode       // 静态子节点
+	params     []*treeNode       // 参数子节点，有约束的优先，其余按注册顺序匹配
+	catchAll   *treeNode         // ** 子节点
+	routerName string            // 完整的路由，注册的时候确定
+	routes     map[string]*Route // 路由按请求方法注册的处理函数
+	isEnd      bool              // 是否是一个完整的路由
+	maxParams  int               // 路由中参数最多的个数，只在根节点上记录
+}
+
+// routeMatch 一次路由匹配的结果，匹配过程中不会修改路由树，多个请求可以并发匹配
+type routeMatch struct {
+	routerName string            // 匹配到的路由，如 /user/get/:id
+	params     Params            // 捕获到的路径参数
+	routes     map[string]*Route // 该路由按请求方法注册的处理函数
+}
+
+// anyMethods ANY 路由允许的请求方法
+var anyMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// route 获取请求方法对应的路由，单独注册的请求方法优先于 ANY，
+// HEAD 没有单独注册时依次使用 GET、ANY 的路由，noBody 表示不返回响应体
+func (m routeMatch) route(method string) (rt *Route, noBody bool) {
+	if rt, ok := m.routes[method]; ok {
+		return rt, false
+	}
+	if method == http.MethodHead {
+		if rt, ok := m.routes[http.MethodGet]; ok {
+			return rt, true
+		}
+	}
+	return m.routes[ANY], method == http.MethodHead
+}
+
+// allow 该路由允许的请求方法，用于 Allow 响应头
+// 注册了 GET 就支持 HEAD，OPTIONS 总是支持的
+func (m routeMatch) allow() string {
+	if _, ok := m.routes[ANY]; ok {
+		return strings.Join(anyMethods, ", ")
+	}
+	methods := make([]string, 0, len(m.routes)+2)
+	for method := range m.routes {
+		methods = append(methods, method)
+	}
+	if _, ok := m.routes[http.MethodGet]; ok {
+		if _, ok := m.routes[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	if _, ok := m.routes[http.MethodOptions]; !ok {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// isWildcard 判断路径段是否是通配符 :id :id<int> {id:[0-9]+} * **
+func isWildcard(segment string) bool {
+	return segment == "*" || segment == "**" ||
+		(len(segment) > 1 && segment[0] == ':') ||
+		(len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}')
+}
+
+// longestCommonPrefix 两个字符串的最长公共前缀长度
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
 }
 
 //put path: /user/get/:id
+// 通配符必须占据一个完整的路径段，** 只能出现在路由的最后
+// 同一位置上参数名不同的参数节点（/user/:id 和 /user/:name、/user/*）会直接 panic
+// strict 为 true 时，同一位置上也不允许参数节点和 ** 同时存在（/user/:id 和 /user/**）
+// 返回路由对应的节点
 
-func (t *treeNode) Put(path string) {
-	root := t
-	strs := strings.Split(path, "/")
-	for index, name := range strs {
-		if index == 0 {
-			continue
-		}
-		children := t.children
-		isMatch := false
-		for _, node := range children {
-			if node.name == name {
-				isMatch = true
-				t = node
-				break
+func (t *treeNode) Put(path string, strict bool) *treeNode {
+	node := t
+	paramCount := 0
+	start := 0 // 还未插入的静态部分的起始位置
+	for segStart := 0; segStart <= len(path); {
+		segEnd := strings.IndexByte(path[segStart:], '/')
+		if segEnd < 0 {
+			segEnd = len(path)
+		} else {
+			segEnd += segStart
+		}
+		segment := path[segStart:segEnd]
+		if isWildcard(segment) {
+			if start < segStart {
+				node = node.addStatic(path[start:segStart])
+			}
+			if segment == "**" {
+				if segEnd != len(path) {
+					panic("msgo: '**' must be at the end of path '" + path + "'")
+				}
+				node = node.addCatchAll(path, strict)
+			} else {
+				node = node.addParam(segment, path, strict)
 			}
+			paramCount++
+			start = segEnd
+		} else if strings.HasPrefix(segment, "{") {
+			// 约束中含有 / 时会被拆成多个路径段，{name:[a-z]+/[0-9]+}
+			panic("msgo: invalid param '" + segment + "' in route '" + path + "', missing '}' or constraint contains '/'")
+		}
+		segStart = segEnd + 1
+	}
+	if start < len(path) {
+		node = node.addStatic(path[start:])
+	}
+	node.isEnd = true
+	node.routerName = path
+	if paramCount > t.maxParams {
+		t.maxParams = paramCount
+	}
+	return node
+}
+
+// addStatic 插入静态路径，公共前缀不同时拆分已有节点
+func (t *treeNode) addStatic(path string) *treeNode {
+	node := t
+	for {
+		index := strings.IndexByte(node.indices, path[0])
+		if index < 0 {
+			child := &treeNode{name: path, nType: staticNode}
+			node.indices += string(path[0])
+			node.children = append(node.children, child)
+			return child
+		}
+		child := node.children[index]
+		i := longestCommonPrefix(path, child.name)
+		if i < len(child.name) {
+			child.split(i)
+		}
+		if i == len(path) {
+			return child
+		}
+		path = path[i:]
+		node = child
+	}
+}
+
+// split 在 i 处将静态节点拆分成两个节点，原节点保留公共前缀
+func (t *treeNode) split(i int) {
+	tail := &treeNode{
+		name:       t.name[i:],
+		nType:      staticNode,
+		indices:    t.indices,
+		children:   t.children,
+		params:     t.params,
+		catchAll:   t.catchAll,
+		routerName: t.routerName,
+		routes:     t.routes,
+		isEnd:      t.isEnd,
+	}
+	*t = treeNode{
+		name:     t.name[:i],
+		nType:    staticNode,
+		indices:  tail.name[:1],
+		children: []*treeNode{tail},
+	}
+}
+
+// addParam 插入参数节点 :id 或 *，path 为正在注册的完整路由，用于冲突提示
+// 同一位置上约束相同但参数名不同的参数节点是冲突的，约束不同的参数节点可以共存，依次尝试匹配
+func (t *treeNode) addParam(name string, path string, strict bool) *treeNode {
+	key, constraint := parseParam(name, path)
+	for _, child := range t.params {
+		if child.key == key && child.constraint == constraint {
+			return child
+		}
+		if strict || child.constraint == constraint {
+			panic(fmt.Sprintf("msgo: wildcard '%s' in route '%s' conflicts with existing wildcard '%s' in route '%s'",
+				name, path, child.name, child.anyRoute()))
 		}
-		if !isMatch {
-			isEnd := false
-			if index == len(strs)-1 {
-				isEnd = true
+	}
+	if strict && t.catchAll != nil {
+		panic(fmt.Sprintf("msgo: wildcard '%s' in route '%s' is ambiguous with existing wildcard '**' in route '%s'",
+			name, path, t.catchAll.anyRoute()))
+	}
+	child := &treeNode{
+		name:       name,
+		nType:      paramNode,
+		key:        key,
+		constraint: constraint,
+		check:      compileConstraint(constraint, path),
+	}
+	// 有约束的参数节点排在没有约束的前面
+	index := len(t.params)
+	if child.check != nil {
+		for i, c := range t.params {
+			if c.check == nil {
+				index = i
+				break
 			}
-			node := &treeNode{name: name, children: make([]*treeNode, 0), isEnd: isEnd}
-			children = append(children, node)
-			t.children = children
-			t = node
 		}
 	}
-	t = root
+	t.params = append(t.params, nil)
+	copy(t.params[index+1:], t.params[index:])
+	t.params[index] = child
+	return child
+}
+
+// addCatchAll 插入 ** 节点
+func (t *treeNode) addCatchAll(path string, strict bool) *treeNode {
+	if strict && len(t.params) > 0 {
+		panic(fmt.Sprintf("msgo: wildcard '**' in route '%s' is ambiguous with existing wildcard '%s' in route '%s'",
+			path, t.params[0].name, t.params[0].anyRoute()))
+	}
+	if t.catchAll == nil {
+		t.catchAll = &treeNode{name: "**", nType: catchAllNode, key: "**"}
+	}
+	return t.catchAll
+}
+
+// anyRoute 返回经过该节点的任意一个已注册的路由
+func (t *treeNode) anyRoute() string {
+	if t.isEnd {
+		return t.routerName
+	}
+	for _, child := range t.children {
+		if route := child.anyRoute(); route != "" {
+			return route
+		}
+	}
+	for _, child := range t.params {
+		if route := child.anyRoute(); route != "" {
+			return route
+		}
+	}
+	if t.catchAll != nil {
+		return t.catchAll.anyRoute()
+	}
+	return ""
 }
 
 // get path: /user/get/1
-func (t *treeNode) Get(path string) *treeNode {
-	strs := strings.Split(path, "/")
-	routerName := ""
-	for index, name := range strs {
-		if index == 0 {
-			continue
-		}
-		children := t.children
-		isMatch := false
-		for _, node := range children {
-			if node.name == name ||
-				node.name == "*" ||
-				strings.Contains(node.name, ":") {
-				isMatch = true
-				routerName += "/" + node.name
-				node.routerName = routerName
-				t = node
-				if index == len(strs)-1 {
-					return node
+// 匹配过程中捕获的路径参数追加到 params 中返回，params 容量足够时不会分配内存
+func (t *treeNode) Get(path string, params Params) (*treeNode, Params) {
+	return t.search(path, params)
+}
+
+// match 查找 path 对应的路由，返回只读的匹配结果
+func (t *treeNode) match(path string, params Params) (routeMatch, bool) {
+	node, params := t.search(path, params)
+	if node == nil || !node.isEnd {
+		return routeMatch{}, false
+	}
+	return routeMatch{routerName: node.routerName, params: params, routes: node.routes}, true
+}
+
+// search path 为去掉当前节点后剩余的路径
+func (t *treeNode) search(path string, params Params) (*treeNode, Params) {
+	if path == "" && t.isEnd {
+		return t, params
+	}
+	if path != "" {
+		// 静态节点优先
+		if index := strings.IndexByte(t.indices, path[0]); index >= 0 {
+			child := t.children[index]
+			if len(path) >= len(child.name) && path[:len(child.name)] == child.name {
+				if node, ps := child.search(path[len(child.name):], params); node != nil {
+					return node, ps
 				}
-				break
 			}
 		}
-		if !isMatch {
-			for _, node := range children {
-				// /user/**
-				// /user/get/userInfo
-				// /user/aa/bb
-				if node.name == "**" {
-					routerName += "/" + node.name
-					node.routerName = routerName
-					return node
+		// 参数节点匹配一个完整的路径段
+		if len(t.params) > 0 {
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			if end > 0 {
+				for _, child := range t.params {
+					if child.check != nil && !child.check(path[:end]) {
+						continue
+					}
+					ps := append(params, Param{Key: child.key, Value: path[:end]})
+					if node, ps := child.search(path[end:], ps); node != nil {
+						return node, ps
+					}
 				}
 			}
+		}
+	}
+	// /user/**
+	// /user/get/userInfo
+	// /user/aa/bb
+	if t.catchAll != nil {
+		return t.catchAll, append(params, Param{Key: t.catchAll.key, Value: path})
+	}
+	return nil, params
+}
 
+// findCaseInsensitive 忽略大小写查找路由，返回路由中实际的路径，参数部分保留请求中的值
+// fixTrailingSlash 为 true 时，末尾多了或者少了 / 也可以匹配
+func (t *treeNode) findCaseInsensitive(path string, fixTrailingSlash bool) (string, bool) {
+	buf, ok := t.searchCaseInsensitive(path, make([]byte, 0, len(path)+1), fixTrailingSlash)
+	return string(buf), ok
+}
+
+func (t *treeNode) searchCaseInsensitive(path string, buf []byte, fixTrailingSlash bool) ([]byte, bool) {
+	if path == "" {
+		if t.isEnd {
+			return buf, true
+		}
+		if fixTrailingSlash {
+			if index := strings.IndexByte(t.indices, '/'); index >= 0 {
+				if child := t.children[index]; child.name == "/" && child.isEnd {
+					return append(buf, '/'), true
+				}
+			}
+		}
+	} else {
+		for _, child := range t.children {
+			n := len(child.name)
+			if len(path) >= n && strings.EqualFold(path[:n], child.name) {
+				if b, ok := child.searchCaseInsensitive(path[n:], append(buf, child.name...), fixTrailingSlash); ok {
+					return b, true
+				}
+			}
+		}
+		if fixTrailingSlash && path == "/" && t.isEnd {
+			return buf, true
 		}
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			for _, child := range t.params {
+				if child.check != nil && !child.check(path[:end]) {
+					continue
+				}
+				if b, ok := child.searchCaseInsensitive(path[end:], append(buf, path[:end]...), fixTrailingSlash); ok {
+					return b, true
+				}
+			}
+		}
+	}
+	if t.catchAll != nil {
+		return append(buf, path...), true
 	}
-	return nil
+	return buf, false
 }
@@ -1,13 +1,294 @@
 package msgo
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
 
 func TestTreeNode(t *testing.T) {
-	root := &treeNode{
-		name:     "/",
-		children: make([]*treeNode, 0),
+	root := &treeNode{}
+	root.Put("/user/get/:id", false)
+	root.Put("/user/create/hello", false)
+	root.Get("/user/get/1", nil)
+}
+
+func TestTreeNodeParams(t *testing.T) {
+	root := &treeNode{}
+	root.Put("/order/get/:id", false)
+	root.Put("/file/*/info", false)
+	root.Put("/static/**", false)
+
+	tests := []struct {
+		path  string
+		key   string
+		value string
+	}{
+		{"/order/get/12", "id", "12"},
+		{"/file/abc/info", "*", "abc"},
+		{"/static/css/main.css", "**", "css/main.css"},
+		{"/static/", "**", ""},
+	}
+	for _, tt := range tests {
+		node, params := root.Get(tt.path, nil)
+		if node == nil {
+			t.Fatalf("%s: no route matched", tt.path)
+		}
+		if value, ok := params.Get(tt.key); !ok || value != tt.value {
+			t.Errorf("%s: param %s = %q, want %q", tt.path, tt.key, value, tt.value)
+		}
+	}
+}
+
+func TestTreeNodePriority(t *testing.T) {
+	root := &treeNode{}
+	// 注册顺序不影响匹配结果
+	root.Put("/user/:id", false)
+	root.Put("/user/:id/posts", false)
+	root.Put("/user/**", false)
+	root.Put("/user/new", false)
+	root.Put("/user/new/profile", false)
+	root.Put("/users", false)
+	root.Put("/us", false)
+
+	tests := []struct {
+		path       string
+		routerName string
+		params     Params
+	}{
+		{"/user/new", "/user/new", nil},
+		{"/user/newer", "/user/:id", Params{{"id", "newer"}}},
+		{"/user/new/profile", "/user/new/profile", nil},
+		// 静态节点 new 匹配失败后回溯到参数节点
+		{"/user/new/posts", "/user/:id/posts", Params{{"id", "new"}}},
+		{"/user/12/posts", "/user/:id/posts", Params{{"id", "12"}}},
+		{"/user/12/comments", "/user/**", Params{{"**", "12/comments"}}},
+		{"/users", "/users", nil},
+		{"/us", "/us", nil},
+		{"/u", "", nil},
+		{"/user", "", nil},
+	}
+	for _, tt := range tests {
+		node, params := root.Get(tt.path, nil)
+		if tt.routerName == "" {
+			if node != nil {
+				t.Errorf("%s: matched %s, want no match", tt.path, node.routerName)
+			}
+			continue
+		}
+		if node == nil {
+			t.Errorf("%s: no route matched, want %s", tt.path, tt.routerName)
+			continue
+		}
+		if node.routerName != tt.routerName {
+			t.Errorf("%s: matched %s, want %s", tt.path, node.routerName, tt.routerName)
+		}
+		if fmt.Sprint(params) != fmt.Sprint(tt.params) {
+			t.Errorf("%s: params %v, want %v", tt.path, params, tt.params)
+		}
+	}
+}
+
+func TestTreeNodeGetNoAllocs(t *testing.T) {
+	root := &treeNode{}
+	for _, route := range benchRoutes() {
+		root.Put(route, false)
+	}
+	params := make(Params, 0, root.maxParams)
+	allocs := testing.AllocsPerRun(100, func() {
+		root.Get("/api/v1/resource99/abc/items/xyz", params[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("Get allocs = %v, want 0", allocs)
+	}
+}
+
+func TestTreeNodeConflict(t *testing.T) {
+	tests := []struct {
+		routes []string
+		strict bool
+		panic  bool
+	}{
+		{[]string{"/user/:id", "/user/:id/posts"}, false, false},
+		{[]string{"/user/:id", "/user/:name"}, false, true},
+		{[]string{"/user/:id", "/user/*"}, false, true},
+		{[]string{"/user/:id/posts", "/user/:name/comments"}, false, true},
+		{[]string{"/user/:id", "/user/new"}, true, false},
+		{[]string{"/user/:id", "/user/**"}, false, false},
+		{[]string{"/user/:id", "/user/**"}, true, true},
+		{[]string{"/user/**", "/user/*"}, true, true},
+		{[]string{"/user/**/posts"}, false, true},
+		{[]string{"/user/:id<int>", "/user/:name"}, false, false},
+		{[]string{"/user/:id<int>", "/user/{uid:int}"}, false, true},
+		{[]string{"/user/:id<int>", "/user/:name"}, true, true},
+		{[]string{"/user/:id<[0-9+>"}, false, true},
+		{[]string{"/f/{name:[a-z]+/[0-9]+}"}, false, true},
+		{[]string{"/f/:name<[a-z]+/[0-9]+>"}, false, true},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				err := recover()
+				if (err != nil) != tt.panic {
+					t.Errorf("%v strict=%v: panic %v, want panic %v", tt.routes, tt.strict, err, tt.panic)
+				}
+			}()
+			root := &treeNode{}
+			for _, route := range tt.routes {
+				root.Put(route, tt.strict)
+			}
+		}()
+	}
+}
+
+func TestTreeNodeConstraint(t *testing.T) {
+	root := &treeNode{}
+	root.Put("/article/:id<int>", false)
+	root.Put("/article/{slug:[a-z0-9_-]+}", false)
+	root.Put("/article/:name", false)
+	root.Put("/file/:uuid<uuid>", false)
+
+	tests := []struct {
+		path       string
+		routerName string
+		key        string
+		value      string
+	}{
+		{"/article/12", "/article/:id<int>", "id", "12"},
+		{"/article/hello_world", "/article/{slug:[a-z0-9_-]+}", "slug", "hello_world"},
+		{"/article/Hello", "/article/:name", "name", "Hello"},
+		{"/file/123e4567-e89b-12d3-a456-426614174000", "/file/:uuid<uuid>", "uuid", "123e4567-e89b-12d3-a456-426614174000"},
+		{"/file/123", "", "", ""},
+	}
+	for _, tt := range tests {
+		node, params := root.Get(tt.path, nil)
+		if tt.routerName == "" {
+			if node != nil {
+				t.Errorf("%s: matched %s, want no match", tt.path, node.routerName)
+			}
+			continue
+		}
+		if node == nil || node.routerName != tt.routerName {
+			t.Errorf("%s: matched %v, want %s", tt.path, node, tt.routerName)
+			continue
+		}
+		if value := params.ByName(tt.key); value != tt.value {
+			t.Errorf("%s: param %s = %q, want %q", tt.path, tt.key, value, tt.value)
+		}
+	}
+}
+
+// benchRoutes 模拟网关中注册的 400 个路由
+func benchRoutes() []string {
+	routes := make([]string, 0, 400)
+	for i := 0; i < 100; i++ {
+		routes = append(routes,
+			fmt.Sprintf("/api/v1/resource%d", i),
+			fmt.Sprintf("/api/v1/resource%d/:id", i),
+			fmt.Sprintf("/api/v1/resource%d/:id/items", i),
+			fmt.Sprintf("/api/v1/resource%d/:id/items/:item", i),
+		)
+	}
+	return routes
+}
+
+var benchPaths = []string{
+	"/api/v1/resource0",
+	"/api/v1/resource50/123",
+	"/api/v1/resource99/abc/items",
+	"/api/v1/resource99/abc/items/xyz",
+}
+
+func BenchmarkTreeNodeGet(b *testing.B) {
+	root := &treeNode{}
+	for _, route := range benchRoutes() {
+		root.Put(route, false)
+	}
+	params := make(Params, 0, root.maxParams)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			root.Get(path, params[:0])
+		}
+	}
+}
+
+func BenchmarkLegacyTreeNodeGet(b *testing.B) {
+	root := &legacyTreeNode{name: "/", children: make([]*legacyTreeNode, 0)}
+	for _, route := range benchRoutes() {
+		root.Put(route)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			root.Get(path)
+		}
+	}
+}
+
+// legacyTreeNode 原来按路径段线性查找的路由树，用于基准测试对比
+type legacyTreeNode struct {
+	name       string
+	children   []*legacyTreeNode
+	routerName string
+	isEnd      bool
+}
+
+func (t *legacyTreeNode) Put(path string) {
+	strs := strings.Split(path, "/")
+	for index, name := range strs {
+		if index == 0 {
+			continue
+		}
+		isMatch := false
+		for _, node := range t.children {
+			if node.name == name {
+				isMatch = true
+				t = node
+				break
+			}
+		}
+		if !isMatch {
+			node := &legacyTreeNode{name: name, children: make([]*legacyTreeNode, 0), isEnd: index == len(strs)-1}
+			t.children = append(t.children, node)
+			t = node
+		}
+	}
+}
+
+func (t *legacyTreeNode) Get(path string) *legacyTreeNode {
+	strs := strings.Split(path, "/")
+	routerName := ""
+	for index, name := range strs {
+		if index == 0 {
+			continue
+		}
+		isMatch := false
+		for _, node := range t.children {
+			if node.name == name ||
+				node.name == "*" ||
+				strings.Contains(node.name, ":") {
+				isMatch = true
+				routerName += "/" + node.name
+				node.routerName = routerName
+				t = node
+				if index == len(strs)-1 {
+					return node
+				}
+				break
+			}
+		}
+		if !isMatch {
+			for _, node := range t.children {
+				if node.name == "**" {
+					routerName += "/" + node.name
+					node.routerName = routerName
+					return node
+				}
+			}
+		}
 	}
-	root.Put("/user/get/:id")
-	root.Put("/user/create/hello")
-	root.Get("/user/get/1")
+	return nil
 }
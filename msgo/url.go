@@ -0,0 +1,87 @@
+package msgo
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+// URL 根据路由名称生成路径，params 中路由用到的参数填充到路径中，其余的作为查询参数
+// e.URL("order.get", map[string]any{"id": 1, "page": 2}) -> /order/get/1?page=2
+// 只生成路径，Host 分组中的路由不包含域名，params 中的域名参数会被忽略
+func (e *Engine) URL(name string, params map[string]any) (string, error) {
+	rt, ok := e.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("msgo: route '%s' not found", name)
+	}
+	used := make(map[string]bool, len(params))
+	for _, label := range strings.Split(rt.group.host, ".") {
+		if isHostParam(label) {
+			used[label[1:]] = true
+		}
+	}
+	segments := strings.Split(rt.path, "/")
+	for i, segment := range segments {
+		if !isWildcard(segment) {
+			continue
+		}
+		key := "**"
+		if segment != key {
+			key, _ = parseParam(segment, rt.path)
+		}
+		value, ok := params[key]
+		if !ok {
+			return "", fmt.Errorf("msgo: missing param '%s' for route '%s' (%s)", key, name, rt.path)
+		}
+		used[key] = true
+		if key == "**" {
+			// ** 中的 / 需要保留
+			parts := strings.Split(fmt.Sprint(value), "/")
+			for j, part := range parts {
+				parts[j] = url.PathEscape(part)
+			}
+			segments[i] = strings.Join(parts, "/")
+		} else {
+			segments[i] = url.PathEscape(fmt.Sprint(value))
+		}
+	}
+	path := strings.Join(segments, "/")
+	query := url.Values{}
+	for key, value := range params {
+		if !used[key] {
+			query.Set(key, fmt.Sprint(value))
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+// FuncMap 返回模板中可以使用的函数，包括内置的 url 和 SetFuncMap 设置的函数，
+// 自己解析模板再调用 SetHtmlTemplate 时需要先 Funcs(engine.FuncMap())
+// {{ url "order.get" "id" 1 }}
+func (e *Engine) FuncMap() template.FuncMap {
+	funcMap := template.FuncMap{
+		"url": func(name string, pairs ...any) (string, error) {
+			if len(pairs)%2 != 0 {
+				return "", errors.New("msgo: url needs key value pairs")
+			}
+			params := make(map[string]any, len(pairs)/2)
+			for i := 0; i < len(pairs); i += 2 {
+				key, ok := pairs[i].(string)
+				if !ok {
+					return "", fmt.Errorf("msgo: url param key %v is not a string", pairs[i])
+				}
+				params[key] = pairs[i+1]
+			}
+			return e.URL(name, params)
+		},
+	}
+	for name, fn := range e.funcMap {
+		funcMap[name] = fn
+	}
+	return funcMap
+}
@@ -1,6 +1,8 @@
 package msgo
 
 import (
+	"path"
+	"strings"
 	"unicode"
 	"unsafe"
 )
@@ -24,3 +26,18 @@ func StringToBytes(s string) []byte {
 		}{s, len(s)},
 	))
 }
+
+// cleanPath 清理请求路径 //user/../user/hello/ -> /user/hello/，保留末尾的 /
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
@@ -0,0 +1,64 @@
+package msgo
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WrapH 将标准库的 http.Handler 转换成 HandlerFunc
+func WrapH(h http.Handler) HandlerFunc {
+	return func(ctx *Context) {
+		h.ServeHTTP(ctx.W, ctx.R)
+	}
+}
+
+// WrapF 将标准库的 http.HandlerFunc 转换成 HandlerFunc
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		f(ctx.W, ctx.R)
+	}
+}
+
+// WrapMiddleware 将标准库风格的中间件 func(http.Handler) http.Handler 转换成 MiddlewareFunc
+// 中间件替换的 ResponseWriter 和 Request 对后面的处理函数生效，返回后恢复
+func WrapMiddleware(middleware func(http.Handler) http.Handler) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			w, r := ctx.W, ctx.R
+			middleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				ctx.R = r
+				if mw, ok := rw.(ResponseWriter); ok {
+					ctx.W = mw
+					next(ctx)
+					return
+				}
+				// 中间件替换成了自己的 ResponseWriter，重新包装一层，处理完后发送响应头
+				wrapped := &responseWriter{}
+				wrapped.reset(rw, ctx.Logger)
+				ctx.W = wrapped
+				next(ctx)
+				wrapped.WriteHeaderNow()
+			})).ServeHTTP(w, r)
+			ctx.W, ctx.R = w, r
+		}
+	}
+}
+
+// Mount 将 prefix 下的所有请求交给 handler 处理，handler 收到的路径去掉了 prefix
+// handler 可以是另一个 *Engine，g.Mount("/legacy", legacyMux)
+func (r *routerGroup) Mount(prefix string, handler http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	h := func(ctx *Context) {
+		req := new(http.Request)
+		*req = *ctx.R
+		u := *ctx.R.URL
+		u.Path = "/" + ctx.CatchAll()
+		u.RawPath = ""
+		req.URL = &u
+		handler.ServeHTTP(ctx.W, req)
+	}
+	if prefix != "" {
+		r.Any(prefix, h)
+	}
+	r.Any(prefix+"/**", h)
+}